@@ -3,9 +3,22 @@ module github.com/luoyanglang/dujiao-migrate
 go 1.21
 
 require (
+	github.com/dop251/goja v0.0.0-20240220182346-e401ed450204
 	github.com/go-sql-driver/mysql v1.7.1
 	github.com/lib/pq v1.10.9
 	github.com/mattn/go-sqlite3 v1.14.18
 	github.com/mozillazg/go-pinyin v0.20.0
+	github.com/pkg/sftp v1.13.6
+	github.com/shopspring/decimal v1.3.1
+	golang.org/x/crypto v0.21.0
+	golang.org/x/text v0.14.0
 	gopkg.in/yaml.v3 v3.0.1
 )
+
+require (
+	github.com/dlclark/regexp2 v1.7.0 // indirect
+	github.com/go-sourcemap/sourcemap v2.1.3+incompatible // indirect
+	github.com/google/pprof v0.0.0-20230207041349-798e818bf904 // indirect
+	github.com/kr/fs v0.1.0 // indirect
+	golang.org/x/sys v0.18.0 // indirect
+)
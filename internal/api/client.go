@@ -47,7 +47,7 @@ func (c *Client) Login(username, password string) error {
 		"password": password,
 	}
 
-	resp, err := c.post("/login", payload, false)
+	resp, err := c.send("POST", "/login", payload, false)
 	if err != nil {
 		return fmt.Errorf("登录请求失败: %w", err)
 	}
@@ -72,7 +72,17 @@ func (c *Client) Login(username, password string) error {
 
 // Post 发送 POST 请求
 func (c *Client) Post(endpoint string, payload interface{}) (*Response, error) {
-	return c.post(endpoint, payload, true)
+	return c.send("POST", endpoint, payload, true)
+}
+
+// Put 发送 PUT 请求
+func (c *Client) Put(endpoint string, payload interface{}) (*Response, error) {
+	return c.send("PUT", endpoint, payload, true)
+}
+
+// Delete 发送 DELETE 请求
+func (c *Client) Delete(endpoint string) (*Response, error) {
+	return c.send("DELETE", endpoint, nil, true)
 }
 
 // Get 发送 GET 请求
@@ -80,7 +90,7 @@ func (c *Client) Get(endpoint string) (*Response, error) {
 	return c.get(endpoint)
 }
 
-func (c *Client) post(endpoint string, payload interface{}, withAuth bool) (*Response, error) {
+func (c *Client) send(method, endpoint string, payload interface{}, withAuth bool) (*Response, error) {
 	var lastErr error
 
 	for attempt := 0; attempt < c.retryTimes; attempt++ {
@@ -88,18 +98,24 @@ func (c *Client) post(endpoint string, payload interface{}, withAuth bool) (*Res
 			time.Sleep(c.retryDelay)
 		}
 
-		data, err := json.Marshal(payload)
-		if err != nil {
-			return nil, fmt.Errorf("序列化请求数据失败: %w", err)
+		var body io.Reader
+		if payload != nil {
+			data, err := json.Marshal(payload)
+			if err != nil {
+				return nil, fmt.Errorf("序列化请求数据失败: %w", err)
+			}
+			body = bytes.NewBuffer(data)
 		}
 
-		req, err := http.NewRequest("POST", c.baseURL+endpoint, bytes.NewBuffer(data))
+		req, err := http.NewRequest(method, c.baseURL+endpoint, body)
 		if err != nil {
 			lastErr = err
 			continue
 		}
 
-		req.Header.Set("Content-Type", "application/json")
+		if body != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
 		if withAuth && c.token != "" {
 			req.Header.Set("Authorization", "Bearer "+c.token)
 		}
@@ -110,7 +126,7 @@ func (c *Client) post(endpoint string, payload interface{}, withAuth bool) (*Res
 			continue
 		}
 
-		body, err := io.ReadAll(resp.Body)
+		respBody, err := io.ReadAll(resp.Body)
 		resp.Body.Close()
 		if err != nil {
 			lastErr = err
@@ -118,7 +134,7 @@ func (c *Client) post(endpoint string, payload interface{}, withAuth bool) (*Res
 		}
 
 		var result Response
-		if err := json.Unmarshal(body, &result); err != nil {
+		if err := json.Unmarshal(respBody, &result); err != nil {
 			lastErr = err
 			continue
 		}
@@ -158,6 +174,15 @@ func (c *Client) get(endpoint string) (*Response, error) {
 
 // UploadFile 上传文件
 func (c *Client) UploadFile(filePath string) (*Response, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("打开文件失败: %w", err)
+	}
+	return c.UploadBytes(filepath.Base(filePath), data)
+}
+
+// UploadBytes 上传文件内容
+func (c *Client) UploadBytes(filename string, data []byte) (*Response, error) {
 	var lastErr error
 
 	for attempt := 0; attempt < c.retryTimes; attempt++ {
@@ -165,25 +190,17 @@ func (c *Client) UploadFile(filePath string) (*Response, error) {
 			time.Sleep(c.retryDelay)
 		}
 
-		// 打开文件
-		file, err := os.Open(filePath)
-		if err != nil {
-			return nil, fmt.Errorf("打开文件失败: %w", err)
-		}
-
 		// 创建 multipart form
 		body := &bytes.Buffer{}
 		writer := multipart.NewWriter(body)
 
-		part, err := writer.CreateFormFile("file", filepath.Base(filePath))
+		part, err := writer.CreateFormFile("file", filename)
 		if err != nil {
-			file.Close()
 			lastErr = err
 			continue
 		}
 
-		if _, err := io.Copy(part, file); err != nil {
-			file.Close()
+		if _, err := part.Write(data); err != nil {
 			lastErr = err
 			continue
 		}
@@ -191,7 +208,6 @@ func (c *Client) UploadFile(filePath string) (*Response, error) {
 		// 添加 scene 字段
 		writer.WriteField("scene", "goods")
 		writer.Close()
-		file.Close()
 
 		req, err := http.NewRequest("POST", c.baseURL+"/upload", body)
 		if err != nil {
@@ -9,14 +9,23 @@ import (
 
 // Config 配置结构
 type Config struct {
-	OldDB   DBConfig  `yaml:"old_db"`
-	NewAPI  APIConfig `yaml:"new_api"`
-	Options Options   `yaml:"options"`
+	OldDB       DBConfig          `yaml:"old_db"`
+	NewAPI      APIConfig         `yaml:"new_api"`
+	Options     Options           `yaml:"options"`
+	I18n        I18nConfig        `yaml:"i18n"`
+	Slug        SlugConfig        `yaml:"slug"`
+	Filter      FilterConfig      `yaml:"filter"`
+	Category    CategoryConfig    `yaml:"category"`
+	Fields      FieldsConfig      `yaml:"fields"`
+	Price       PriceConfig       `yaml:"price"`
+	Fulfillment FulfillmentConfig `yaml:"fulfillment"`
+
+	Sources []SourceConfig `yaml:"sources"` // 多个老版站点合并迁移，设置后忽略 old_db
 }
 
 // DBConfig 数据库配置
 type DBConfig struct {
-	Driver   string `yaml:"driver"`   // mysql, postgres, sqlite
+	Driver   string `yaml:"driver"` // mysql, postgres, sqlite
 	Host     string `yaml:"host"`
 	Port     int    `yaml:"port"`
 	User     string `yaml:"user"`
@@ -24,6 +33,34 @@ type DBConfig struct {
 	Database string `yaml:"database"`
 	Charset  string `yaml:"charset"`
 	SSLMode  string `yaml:"ssl_mode"` // for postgres
+
+	PasswordFile string `yaml:"password_file"` // 从文件读取密码，优先于 password
+	TablePrefix  string `yaml:"table_prefix"`  // 数据表前缀，如 dj_
+
+	Socket string            `yaml:"socket"` // Unix socket 路径，设置后忽略 host/port
+	TLS    string            `yaml:"tls"`    // MySQL TLS: true, false, skip-verify, preferred 或 CA 证书路径
+	Params map[string]string `yaml:"params"` // 附加连接参数，如 timeout: 10s
+	DSN    string            `yaml:"dsn"`    // 完整 DSN，设置后忽略其他连接字段
+
+	SSH SSHConfig `yaml:"ssh"` // 通过 SSH 隧道连接老版数据库
+}
+
+// SSHConfig SSH 隧道配置，host 为空时不启用
+type SSHConfig struct {
+	Host                string `yaml:"host"`
+	Port                int    `yaml:"port"`
+	User                string `yaml:"user"`
+	Password            string `yaml:"password"`
+	KeyFile             string `yaml:"key_file"`               // 私钥路径，如 ~/.ssh/id_ed25519
+	Passphrase          string `yaml:"passphrase"`             // 私钥密码
+	KnownHosts          string `yaml:"known_hosts"`            // known_hosts 路径，默认 ~/.ssh/known_hosts
+	InsecureSkipHostKey bool   `yaml:"insecure_skip_host_key"` // 跳过主机密钥校验（仅用于测试）
+	SFTP                bool   `yaml:"sftp"`                   // 通过 SFTP 读取 old_site_path 下的图片
+}
+
+// Enabled 是否启用 SSH 隧道
+func (c SSHConfig) Enabled() bool {
+	return c.Host != ""
 }
 
 // APIConfig API 配置
@@ -31,17 +68,86 @@ type APIConfig struct {
 	BaseURL  string `yaml:"base_url"`
 	Username string `yaml:"username"`
 	Password string `yaml:"password"`
+
+	PasswordFile string `yaml:"password_file"` // 从文件读取密码，优先于 password
 }
 
 // Options 迁移选项
 type Options struct {
-	RetryTimes    int    `yaml:"retry_times"`
-	RetryDelay    int    `yaml:"retry_delay"`
-	SkipExisting  bool   `yaml:"skip_existing"`
-	MigrateCards  bool   `yaml:"migrate_cards"`
-	OnlyActive    bool   `yaml:"only_active"`
-	BatchSize     int    `yaml:"batch_size"`
-	OldSitePath   string `yaml:"old_site_path"`
+	RetryTimes     int      `yaml:"retry_times"`
+	RetryDelay     int      `yaml:"retry_delay"`
+	SkipExisting   bool     `yaml:"skip_existing"` // 旧选项，未设置 on_existing 时 true 等同 skip，false 等同 duplicate
+	OnExisting     string   `yaml:"on_existing"`   // 已存在数据的处理方式: skip, update, duplicate
+	MatchBy        []string `yaml:"match_by"`      // 判断已存在的方式，按顺序尝试: external_id, slug, title
+	MigrateCards   bool     `yaml:"migrate_cards"`
+	OnlyActive     bool     `yaml:"only_active"`
+	BatchSize      int      `yaml:"batch_size"`
+	OldSitePath    string   `yaml:"old_site_path"`
+	ImageURLPrefix string   `yaml:"image_url_prefix"` // 未上传图片的访问地址前缀，默认取自 .env 的 APP_URL
+	StateFile      string   `yaml:"state_file"`       // 迁移状态文件（已上传图片等），留空不保存
+	OnDeleted      string   `yaml:"on_deleted"`       // 增量同步时老版已删除商品的处理方式: deactivate, delete
+	MaxDeletions   int      `yaml:"max_deletions"`    // 每轮同步最多删除/下架的数量，超过时中止，0 不限制
+	VerifyPrices   bool     `yaml:"verify_prices"`    // 创建或更新商品后读取新版价格，与迁移的价格核对
+
+	SoldCardStatuses []string `yaml:"sold_card_statuses"` // 新版卡密列表中表示已售出的 status，同步删除时跳过
+}
+
+// I18nConfig 多语言配置
+type I18nConfig struct {
+	ZhTW     string `yaml:"zh_tw"`      // 繁体生成方式: 空（不生成）, s2t, s2tw, s2twp, s2hk
+	ZhTWDict string `yaml:"zh_tw_dict"` // 自定义繁体词典，每行 "简体<Tab>繁体"
+
+	EnUS              string `yaml:"en_us"`              // 英文翻译方式: 空/none（不翻译）, glossary, http
+	GlossaryFile      string `yaml:"glossary_file"`      // 术语表 CSV，每行 "中文,英文"
+	TranslateEndpoint string `yaml:"translate_endpoint"` // HTTP 翻译接口地址
+	TranslateAPIKey   string `yaml:"translate_api_key"`  // HTTP 翻译接口密钥（Bearer Token）
+	TranslateCache    string `yaml:"translate_cache"`    // 翻译缓存文件，留空不缓存
+}
+
+// SlugConfig slug 生成配置
+type SlugConfig struct {
+	Strategy     string `yaml:"strategy"`      // pinyin, initials, id, hash, unicode
+	MaxLength    int    `yaml:"max_length"`    // 最大长度（字符数）
+	Separator    string `yaml:"separator"`     // 分隔符
+	OverrideFile string `yaml:"override_file"` // 手动指定 slug 的 YAML/CSV 文件
+	PinyinDict   string `yaml:"pinyin_dict"`   // 自定义多音字词组读音
+}
+
+// CategoryConfig 分类映射配置
+type CategoryConfig struct {
+	MappingFile string `yaml:"mapping_file"` // 分类映射文件（YAML）：合并、改名、映射到新版已有分类或不迁移
+	Delimiter   string `yaml:"delimiter"`    // 层级分隔符，如 "/" 时 "游戏/Steam" 创建为「游戏」下的「Steam」，留空不拆分
+}
+
+// RoundingModes 价格取整方式
+var RoundingModes = []string{"cents", "ceil", "99", "none"}
+
+// PriceConfig 价格换算配置
+type PriceConfig struct {
+	Currency       string            `yaml:"currency"`        // 新版价格货币，如 USD
+	SourceCurrency string            `yaml:"source_currency"` // 老版价格货币
+	Rates          map[string]string `yaml:"rates"`           // 汇率表：1 单位老版货币可兑换的目标货币，如 USD: 0.1389
+	RatesFile      string            `yaml:"rates_file"`      // 汇率文件（YAML/JSON），rates 中没有的货币从此文件读取
+	Rounding       string            `yaml:"rounding"`        // 取整方式: cents（四舍五入到分）, ceil（向上取整）, 99（x.99 结尾）, none
+}
+
+// FulfillmentTypes 老版商品类型可以映射的新版发货方式，skip 表示不迁移该类型的商品
+var FulfillmentTypes = []string{"auto", "manual", "skip"}
+
+// FulfillmentConfig 发货方式配置
+type FulfillmentConfig struct {
+	Types        map[string]string `yaml:"types"`         // 老版商品类型 -> 新版发货方式，覆盖内置的对应关系（1: auto, 2: manual）
+	APIHook      string            `yaml:"api_hook"`      // 老版 api_hook（支付后回调地址）的处理方式: ignore（默认）, webhook
+	WebhookField string            `yaml:"webhook_field"` // api_hook 写入的新版商品字段
+}
+
+// FilterConfig 选择性迁移的过滤条件，同时作用于分类、商品和卡密
+type FilterConfig struct {
+	Categories   string `yaml:"categories"`    // 老版分类 ID，如 "3,5,10-20"
+	Products     string `yaml:"products"`      // 老版商品 ID，如 "10-50"
+	Where        string `yaml:"where"`         // 商品表附加 SQL 条件，如 "actual_price > 10"
+	NamePattern  string `yaml:"name_pattern"`  // 商品名称正则
+	CreatedAfter string `yaml:"created_after"` // 只迁移此时间之后创建的商品，如 2024-01-01
 }
 
 // CLIArgs 命令行参数
@@ -52,26 +158,40 @@ type CLIArgs struct {
 	OldPassword string
 	OldDatabase string
 	OldDriver   string
+	OldPrefix   string
 	NewAPI      string
 	NewUser     string
 	NewPassword string
 	NoSkip      bool
+	OnExisting  string
 	NoCards     bool
 	OldSitePath string
+
+	Categories   string
+	Products     string
+	Where        string
+	NamePattern  string
+	CreatedAfter string
+
+	Script   string
+	Currency string
 }
 
 // DefaultConfig 返回默认配置
 func DefaultConfig() *Config {
 	return &Config{
 		OldDB: DBConfig{
-			Driver:  "mysql",
-			Host:    "127.0.0.1",
-			Port:    3306,
-			User:    "root",
+			Driver:   "mysql",
+			Host:     "127.0.0.1",
+			Port:     3306,
+			User:     "root",
 			Password: "",
 			Database: "dujiaoka",
 			Charset:  "utf8mb4",
 			SSLMode:  "disable",
+			SSH: SSHConfig{
+				Port: 22,
+			},
 		},
 		NewAPI: APIConfig{
 			BaseURL:  "http://127.0.0.1:8080/api/v1/admin",
@@ -82,10 +202,34 @@ func DefaultConfig() *Config {
 			RetryTimes:   3,
 			RetryDelay:   1,
 			SkipExisting: true,
+			MatchBy:      []string{"external_id", "slug"},
 			MigrateCards: true,
 			OnlyActive:   true,
 			BatchSize:    500,
 			OldSitePath:  "",
+			StateFile:    "migrate-state.json",
+			OnDeleted:    "deactivate",
+			MaxDeletions: 50,
+			VerifyPrices: true,
+
+			SoldCardStatuses: []string{"sold", "used"},
+		},
+		I18n: I18nConfig{
+			TranslateCache: "translate-cache.json",
+		},
+		Slug: SlugConfig{
+			Strategy:  "pinyin",
+			MaxLength: 50,
+			Separator: "-",
+		},
+		Price: PriceConfig{
+			Currency:       "CNY",
+			SourceCurrency: "CNY",
+			Rounding:       "cents",
+		},
+		Fulfillment: FulfillmentConfig{
+			APIHook:      "ignore",
+			WebhookField: "webhook_url",
 		},
 	}
 }
@@ -94,18 +238,53 @@ func DefaultConfig() *Config {
 func LoadConfig(configFile string, args *CLIArgs) (*Config, error) {
 	cfg := DefaultConfig()
 
-	// 从文件加载
+	var doc *yaml.Node
 	if configFile != "" {
 		data, err := os.ReadFile(configFile)
 		if err != nil {
 			return nil, fmt.Errorf("读取配置文件失败: %w", err)
 		}
+		if doc, err = parseConfigYAML(data); err != nil {
+			return nil, err
+		}
+	}
 
-		if err := yaml.Unmarshal(data, cfg); err != nil {
+	// 从文件加载
+	if doc != nil {
+		if err := decodeConfig(doc, cfg); err != nil {
 			return nil, fmt.Errorf("解析配置文件失败: %w", err)
 		}
 	}
 
+	// 老版站点 .env 中的数据库配置，只填充配置文件中保持默认值或示例值的字段
+	// 通过 SFTP 访问老版站点时 .env 在远程服务器上，由迁移器连接后读取
+	sitePath := args.OldSitePath
+	if sitePath == "" {
+		peek := DefaultConfig()
+		if doc != nil {
+			if err := decodeConfig(doc, peek); err != nil {
+				return nil, fmt.Errorf("解析配置文件失败: %w", err)
+			}
+		}
+		if err := applyEnvOverrides(peek); err != nil {
+			return nil, err
+		}
+		sitePath = peek.Options.OldSitePath
+	}
+	if sitePath != "" && !(cfg.OldDB.SSH.Enabled() && cfg.OldDB.SSH.SFTP) {
+		if err := applyLaravelEnv(cfg, sitePath); err != nil {
+			return nil, err
+		}
+	}
+
+	// 环境变量覆盖
+	if err := applyEnvOverrides(cfg); err != nil {
+		return nil, err
+	}
+	if err := resolvePasswordFiles(cfg); err != nil {
+		return nil, err
+	}
+
 	// 命令行参数覆盖
 	if args.OldHost != "" {
 		cfg.OldDB.Host = args.OldHost
@@ -125,6 +304,9 @@ func LoadConfig(configFile string, args *CLIArgs) (*Config, error) {
 	if args.OldDriver != "" {
 		cfg.OldDB.Driver = args.OldDriver
 	}
+	if args.OldPrefix != "" {
+		cfg.OldDB.TablePrefix = args.OldPrefix
+	}
 	if args.NewAPI != "" {
 		cfg.NewAPI.BaseURL = args.NewAPI
 	}
@@ -136,6 +318,19 @@ func LoadConfig(configFile string, args *CLIArgs) (*Config, error) {
 	}
 	if args.NoSkip {
 		cfg.Options.SkipExisting = false
+		cfg.Options.OnExisting = "duplicate"
+	}
+	if args.OnExisting != "" {
+		cfg.Options.OnExisting = args.OnExisting
+	}
+
+	// 兼容旧选项 skip_existing
+	if cfg.Options.OnExisting == "" {
+		if cfg.Options.SkipExisting {
+			cfg.Options.OnExisting = "skip"
+		} else {
+			cfg.Options.OnExisting = "duplicate"
+		}
 	}
 	if args.NoCards {
 		cfg.Options.MigrateCards = false
@@ -143,6 +338,31 @@ func LoadConfig(configFile string, args *CLIArgs) (*Config, error) {
 	if args.OldSitePath != "" {
 		cfg.Options.OldSitePath = args.OldSitePath
 	}
+	if args.Categories != "" {
+		cfg.Filter.Categories = args.Categories
+	}
+	if args.Products != "" {
+		cfg.Filter.Products = args.Products
+	}
+	if args.Where != "" {
+		cfg.Filter.Where = args.Where
+	}
+	if args.NamePattern != "" {
+		cfg.Filter.NamePattern = args.NamePattern
+	}
+	if args.CreatedAfter != "" {
+		cfg.Filter.CreatedAfter = args.CreatedAfter
+	}
+	if args.Script != "" {
+		cfg.Fields.Script = args.Script
+	}
+	if args.Currency != "" {
+		cfg.Price.Currency = args.Currency
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
 
 	return cfg, nil
 }
@@ -158,9 +378,26 @@ old_db:
   port: 3306
   user: "root"
   password: "your_password"
+  # password: "${DB_PASSWORD}"                 # 引用环境变量，也可写 ${DB_PASSWORD:-默认值}
+  # password_file: "/run/secrets/db_password"  # 从文件读取密码，优先于 password
   database: "dujiaoka"
   charset: "utf8mb4"
   ssl_mode: "disable"      # PostgreSQL SSL 模式: disable, require, verify-ca, verify-full
+  table_prefix: ""         # 数据表前缀，如 dj_（表名为 dj_goods 等）
+  # charset: "latin1"      # 老库用 latin1 表存储 UTF-8 中文时设为 latin1，按原始字节读取避免乱码
+  # socket: "/tmp/mysql.sock"  # 通过 Unix socket 连接，设置后忽略 host/port
+  # tls: "skip-verify"     # MySQL TLS: true, false, skip-verify, preferred 或 CA 证书路径
+  # params:                # 附加连接参数
+  #   timeout: "10s"
+  #   readTimeout: "30s"
+  # dsn: "root:pass@tcp(127.0.0.1:3306)/dujiaoka?charset=utf8mb4&parseTime=true"  # 完整 DSN，设置后忽略以上连接字段
+  # ssh:                    # 通过 SSH 隧道连接（老库只监听 127.0.0.1 时使用）
+  #   host: "old.example.com"
+  #   port: 22
+  #   user: "root"
+  #   key_file: "~/.ssh/id_ed25519"  # 或使用 password
+  #   known_hosts: "~/.ssh/known_hosts"
+  #   sftp: true             # 通过 SFTP 读取 old_site_path 下的图片和 .env
 
 # SQLite 示例:
 # old_db:
@@ -182,16 +419,99 @@ new_api:
   base_url: "http://127.0.0.1:8080/api/v1/admin"
   username: "admin"
   password: "admin123"
+  # password_file: "/run/secrets/api_password"
+
+# 所有字段均可用环境变量覆盖: DUJIAO_MIGRATE_<分组>_<字段>
+# 如 DUJIAO_MIGRATE_OLD_DB_PASSWORD、DUJIAO_MIGRATE_NEW_API_PASSWORD、DUJIAO_MIGRATE_OPTIONS_BATCH_SIZE
 
 # 迁移选项
 options:
   retry_times: 3        # API 请求重试次数
   retry_delay: 1        # 重试间隔（秒）
-  skip_existing: true   # 跳过已存在的数据
+  on_existing: "skip"   # 已存在数据的处理方式: skip 跳过, update 同步变化的字段, duplicate 重新创建
+  match_by: ["external_id", "slug"]  # 判断已存在的方式，按顺序尝试: external_id 老版 ID 标记, slug, title 名称
   migrate_cards: true   # 是否迁移卡密
   only_active: true     # 只迁移已启用的数据
   batch_size: 500       # 卡密批量导入大小
   old_site_path: ""     # 老版站点路径（用于图片迁移，如 /www/wwwroot/dujiaoka）
+                        # 设置后会自动读取站点 .env 中的数据库配置，old_db 中保持示例值的字段由 .env 填充
+  image_url_prefix: ""  # 未上传图片的访问地址前缀，默认取自 .env 的 APP_URL + /uploads
+  state_file: "migrate-state.json"  # 迁移状态（已上传图片等），重复运行时不重复上传
+  on_deleted: "deactivate"  # sync 时老版已删除商品的处理方式: deactivate 下架, delete 删除（已售出/删除的卡密总是删除）
+  max_deletions: 50     # sync 每轮最多下架/删除的数量，超过时中止本轮，防止误删（0 不限制）
+  verify_prices: true   # 创建或更新商品后读取新版价格，与迁移的价格核对
+  sold_card_statuses: ["sold", "used"]  # 新版卡密列表中表示已售出的 status，sync 删除卡密时跳过
+
+# 多语言
+i18n:
+  zh_tw: ""             # 由简体自动生成 zh-TW: s2t（通用繁体）, s2tw（台湾）, s2twp（台湾惯用词）, s2hk（香港），留空不生成
+  zh_tw_dict: ""        # 自定义繁体词典，每行 "简体<Tab>繁体"，优先于内置词典
+  en_us: ""             # 生成 en-US: glossary（本地术语表）, http（HTTP 翻译接口），留空不翻译
+  glossary_file: ""     # 术语表 CSV，每行 "中文,英文"
+  translate_endpoint: ""  # HTTP 翻译接口，POST {"text","source","target"}，返回 {"text"}
+  translate_api_key: ""   # HTTP 翻译接口密钥，以 Bearer Token 发送
+  translate_cache: "translate-cache.json"  # HTTP 翻译缓存，重复运行不会重复翻译
+
+# slug 生成
+slug:
+  strategy: "pinyin"    # pinyin 全拼, initials 拼音首字母, id 老版 ID（如 p-123）, hash 名称哈希, unicode 保留原文
+  max_length: 50        # 最大长度（字符数）
+  separator: "-"        # 分隔符: - _ .
+  override_file: ""     # 手动指定 slug 的文件（.yaml 或 .csv），按老版 ID 或名称匹配，多站点时写成 站点名:ID
+  pinyin_dict: ""       # 自定义词组读音，每行: 词语 拼音 拼音（如 "重庆 chong qing"）
+
+# 分类映射
+category:
+  mapping_file: ""      # 分类映射文件（YAML）：合并、改名、映射到新版已有分类、指定上级分类或不迁移
+  delimiter: ""         # 层级分隔符，如 "/" 时「游戏/Steam」创建为「游戏」下的「Steam」，留空不拆分
+
+# 选择性迁移（留空迁移全部），卡密只迁移选中商品的
+filter:
+  categories: ""        # 老版分类 ID，如 "3,5,10-20"
+  products: ""          # 老版商品 ID，如 "10-50"
+  where: ""             # 商品表附加 SQL 条件，如 "actual_price > 10"
+  name_pattern: ""      # 商品名称正则，如 "Steam|Netflix"
+  created_after: ""     # 只迁移此时间之后创建的商品，如 "2024-01-01"
+
+# 价格换算
+price:
+  currency: "CNY"       # 新版价格货币，如 USD
+  source_currency: "CNY" # 老版价格货币
+  rates: {}             # 汇率表：1 单位老版货币可兑换的目标货币，如 {USD: 0.1389}
+  rates_file: ""        # 汇率文件（YAML/JSON），rates 中没有的货币从此文件读取
+  rounding: "cents"     # 取整: cents（四舍五入到分）, ceil（向上取整）, 99（x.99 结尾）, none
+
+# 发货方式
+fulfillment:
+  types: {}             # 老版商品类型 -> 新版发货方式（auto, manual, skip），覆盖内置的 1: auto、2: manual，如 {"3": manual}
+  api_hook: "ignore"    # 老版 api_hook（支付后回调地址）: ignore（不迁移并提示）, webhook（写入新版回调字段，保存后读取核对）
+  webhook_field: "webhook_url" # api_hook 为 webhook 时写入的新版商品字段
+
+# 新版商品字段规则：按字段覆盖迁移工具生成的值，取老版列、常量或 Go 模板之一
+# fields:
+#   products:
+#     price_currency: USD                                  # 常量
+#     sort_order: {column: ord}                            # 老版商品表的列
+#     price_amount: "{{ round (mul .ActualPrice 1.05) 2 }}"  # 模板
+#   script: ""          # 转换脚本（JavaScript），定义 transformCategory、transformProduct、transformCard
+
+# 多站点合并（设置后忽略 old_db、options.old_site_path 和 --old-* 参数），按顺序迁移到同一个新版站点
+# sources:
+#   - name: "shop-a"               # 站点标识（小写字母、数字、- _），写入 external_id，状态文件为 migrate-state.shop-a.json
+#     old_db:                      # 与 old_db 相同，未填写的字段使用默认值
+#       host: "10.0.0.1"
+#       user: "root"
+#       password: "${SHOP_A_DB_PASSWORD}"
+#       database: "dujiaoka"
+#     old_site_path: "/www/shop-a"
+#     slug_prefix: "a-"            # slug 前缀，避免与其他站点冲突（冲突时也会自动加后缀）
+#     parent_category: "A 店"      # 该站点的分类放在此父分类下，不存在时自动创建
+#     category_prefix: ""          # 分类名称前缀，如 "[A店] "
+#     category_mapping: ""         # 该站点的分类映射文件，覆盖 category.mapping_file
+#   - name: "shop-b"
+#     old_db:
+#       driver: "sqlite"
+#       database: "/data/shop-b.db"
 `
 	fmt.Print(sample)
 }
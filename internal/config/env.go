@@ -0,0 +1,166 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// EnvPrefix 环境变量前缀，如 DUJIAO_MIGRATE_OLD_DB_PASSWORD
+const EnvPrefix = "DUJIAO_MIGRATE_"
+
+// yamlRefPattern 匹配 ${VAR} 和 ${VAR:-默认值}
+var yamlRefPattern = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)(:-([^}]*))?\}`)
+
+// parseConfigYAML 解析配置文件并展开其中的 ${VAR} 引用
+//
+// 先解析为 YAML 节点再只展开标量值，变量内容中的引号、反斜杠、#、": " 和换行原样保留，注释中的引用不处理。
+// 未定义且没有默认值的变量会返回错误，避免空密码等隐蔽问题
+func parseConfigYAML(data []byte) (*yaml.Node, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("解析配置文件失败: %w", err)
+	}
+
+	var missing []string
+	expandEnvNode(&doc, &missing)
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("配置文件引用了未定义的环境变量: %s", strings.Join(missing, ", "))
+	}
+	return &doc, nil
+}
+
+// decodeConfig 把配置文件解码到 cfg，未指定配置文件或文件为空时不修改
+func decodeConfig(doc *yaml.Node, cfg *Config) error {
+	if doc == nil || len(doc.Content) == 0 {
+		return nil
+	}
+	return doc.Decode(cfg)
+}
+
+// expandEnvNode 展开标量值中的 ${VAR} 和 ${VAR:-默认值}，映射的键不展开
+func expandEnvNode(node *yaml.Node, missing *[]string) {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		if !yamlRefPattern.MatchString(node.Value) {
+			return
+		}
+		node.Value = yamlRefPattern.ReplaceAllStringFunc(node.Value, func(ref string) string {
+			groups := yamlRefPattern.FindStringSubmatch(ref)
+			if value, ok := os.LookupEnv(groups[1]); ok {
+				return value
+			}
+			if groups[2] != "" {
+				return groups[3]
+			}
+			*missing = append(*missing, groups[1])
+			return ""
+		})
+		// 未加引号的值按展开后的内容重新判断类型，如 port: ${DB_PORT} 为数字
+		if node.Style&(yaml.SingleQuotedStyle|yaml.DoubleQuotedStyle|yaml.LiteralStyle|yaml.FoldedStyle) == 0 {
+			node.Tag = ""
+		}
+	case yaml.MappingNode:
+		for i := 1; i < len(node.Content); i += 2 {
+			expandEnvNode(node.Content[i], missing)
+		}
+	default:
+		for _, child := range node.Content {
+			expandEnvNode(child, missing)
+		}
+	}
+}
+
+// applyEnvOverrides 使用 DUJIAO_MIGRATE_* 环境变量覆盖配置
+// 变量名由 yaml 字段名逐级拼接而成，如 options.batch_size -> DUJIAO_MIGRATE_OPTIONS_BATCH_SIZE
+func applyEnvOverrides(cfg *Config) error {
+	return applyEnvStruct(reflect.ValueOf(cfg).Elem(), EnvPrefix)
+}
+
+func applyEnvStruct(v reflect.Value, prefix string) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := strings.Split(field.Tag.Get("yaml"), ",")[0]
+		if tag == "" || tag == "-" {
+			continue
+		}
+
+		name := prefix + strings.ToUpper(tag)
+		fv := v.Field(i)
+
+		if fv.Kind() == reflect.Struct {
+			if err := applyEnvStruct(fv, name+"_"); err != nil {
+				return err
+			}
+			continue
+		}
+
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			continue
+		}
+
+		switch fv.Kind() {
+		case reflect.String:
+			fv.SetString(value)
+		case reflect.Int:
+			n, err := strconv.Atoi(strings.TrimSpace(value))
+			if err != nil {
+				return fmt.Errorf("环境变量 %s=%q 不是有效整数", name, value)
+			}
+			fv.SetInt(int64(n))
+		case reflect.Bool:
+			b, err := strconv.ParseBool(strings.TrimSpace(value))
+			if err != nil {
+				return fmt.Errorf("环境变量 %s=%q 不是有效布尔值 (true/false)", name, value)
+			}
+			fv.SetBool(b)
+		case reflect.Slice:
+			if fv.Type().Elem().Kind() != reflect.String {
+				continue
+			}
+			var items []string
+			for _, item := range strings.Split(value, ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					items = append(items, item)
+				}
+			}
+			fv.Set(reflect.ValueOf(items))
+		}
+	}
+	return nil
+}
+
+// readSecretFile 读取密码文件，去掉末尾换行
+func readSecretFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("读取密码文件失败: %w", err)
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// resolvePasswordFiles 使用 password_file 指定的文件内容作为密码
+func resolvePasswordFiles(cfg *Config) error {
+	if cfg.OldDB.PasswordFile != "" {
+		password, err := readSecretFile(cfg.OldDB.PasswordFile)
+		if err != nil {
+			return fmt.Errorf("old_db.password_file: %w", err)
+		}
+		cfg.OldDB.Password = password
+	}
+	if cfg.NewAPI.PasswordFile != "" {
+		password, err := readSecretFile(cfg.NewAPI.PasswordFile)
+		if err != nil {
+			return fmt.Errorf("new_api.password_file: %w", err)
+		}
+		cfg.NewAPI.Password = password
+	}
+	return resolveSourcePasswordFiles(cfg)
+}
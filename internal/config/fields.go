@@ -0,0 +1,44 @@
+package config
+
+import (
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FieldTypes 字段规则的结果类型
+var FieldTypes = []string{"string", "int", "float", "decimal", "bool", "json", "localized"}
+
+// managedFields 迁移工具用于匹配已有数据的字段，不能通过字段规则设置
+var managedFields = []string{"slug", "external_id"}
+
+// FieldsConfig 新版数据的字段规则，按字段覆盖迁移工具生成的值
+type FieldsConfig struct {
+	Products map[string]FieldRule `yaml:"products"` // 商品字段，如 price_currency、purchase_type
+	Script   string               `yaml:"script"`   // 转换脚本（JavaScript），定义 transformCategory、transformProduct、transformCard
+}
+
+// FieldRule 字段取值规则，column、value、template 只能设置一个
+type FieldRule struct {
+	Column   string      `yaml:"column"`   // 老版商品表的列名，如 gd_name
+	Value    interface{} `yaml:"value"`    // 常量
+	Template string      `yaml:"template"` // Go 模板，如 "{{ mul .ActualPrice 1.05 }}"
+	Type     string      `yaml:"type"`     // 结果类型: string, int, float, decimal, bool, json, localized，默认与原字段相同
+}
+
+// UnmarshalYAML 支持简写：含 {{ 的字符串为模板，其他值为常量
+//
+//	price_currency: USD
+//	price_amount: "{{ mul .ActualPrice 1.05 }}"
+//	sort_order: {column: ord}
+func (r *FieldRule) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.MappingNode {
+		type plain FieldRule
+		return value.Decode((*plain)(r))
+	}
+	if value.Kind == yaml.ScalarNode && value.Tag == "!!str" && strings.Contains(value.Value, "{{") {
+		r.Template = value.Value
+		return nil
+	}
+	return value.Decode(&r.Value)
+}
@@ -0,0 +1,176 @@
+package config
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// laravelDrivers Laravel DB_CONNECTION 与本工具驱动名的对应关系
+var laravelDrivers = map[string]string{
+	"mysql":   "mysql",
+	"mariadb": "mysql",
+	"pgsql":   "postgres",
+	"sqlite":  "sqlite",
+}
+
+var envRefPattern = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// ParseDotEnv 解析 Laravel 风格的 .env 文件
+// 支持注释、export 前缀、单双引号以及 ${VAR} 引用已定义的变量
+func ParseDotEnv(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseDotEnvData(data, path)
+}
+
+// ParseDotEnvData 解析 .env 文件内容，name 用于错误提示
+func ParseDotEnvData(data []byte, name string) (map[string]string, error) {
+	env := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("%s 第 %d 行格式错误: %s", name, lineNo, line)
+		}
+		key = strings.TrimSpace(key)
+		value = strings.TrimSpace(value)
+
+		switch {
+		case strings.HasPrefix(value, `"`):
+			value = unquote(value[1:], '"')
+			value = strings.ReplaceAll(value, `\"`, `"`)
+			value = expandEnvRefs(value, env)
+		case strings.HasPrefix(value, "'"):
+			value = unquote(value[1:], '\'')
+		default:
+			// 未加引号的值，去掉行内注释
+			if idx := strings.Index(value, " #"); idx >= 0 {
+				value = strings.TrimSpace(value[:idx])
+			}
+			value = expandEnvRefs(value, env)
+		}
+
+		env[key] = value
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return env, nil
+}
+
+// unquote 截取到结束引号为止的内容，结束引号之后的行内注释被忽略
+func unquote(value string, quote byte) string {
+	for i := 0; i < len(value); i++ {
+		if value[i] == '\\' && quote == '"' {
+			i++
+			continue
+		}
+		if value[i] == quote {
+			return value[:i]
+		}
+	}
+	return value
+}
+
+// expandEnvRefs 展开 ${VAR} 引用，优先使用 .env 中已定义的变量
+func expandEnvRefs(value string, env map[string]string) string {
+	return envRefPattern.ReplaceAllStringFunc(value, func(ref string) string {
+		name := ref[2 : len(ref)-1]
+		if v, ok := env[name]; ok {
+			return v
+		}
+		return os.Getenv(name)
+	})
+}
+
+// samplePassword 示例配置中的占位密码，与默认值一样视为未填写
+const samplePassword = "your_password"
+
+// applyLaravelEnv 从老版站点的 .env 读取数据库配置和站点地址
+// .env 不存在时不做任何修改
+func applyLaravelEnv(cfg *Config, sitePath string) error {
+	envPath := filepath.Join(sitePath, ".env")
+	env, err := ParseDotEnv(envPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("读取老版站点 .env 失败: %w", err)
+	}
+
+	imagePrefix, err := ApplyLaravelEnv(&cfg.OldDB, env, envPath, sitePath)
+	if err != nil {
+		return err
+	}
+	if cfg.Options.ImageURLPrefix == "" {
+		cfg.Options.ImageURLPrefix = imagePrefix
+	}
+	return nil
+}
+
+// ApplyLaravelEnv 用老版站点 .env 中的数据库配置填充 db，返回由 APP_URL 生成的图片地址前缀
+//
+// 只覆盖仍为默认值（或示例配置占位值）的字段，配置文件中改过的字段保持不变；
+// name 用于错误提示，sitePath 用于拼接 SQLite 的相对路径
+func ApplyLaravelEnv(db *DBConfig, env map[string]string, name, sitePath string) (string, error) {
+	def := DefaultConfig().OldDB
+
+	if conn := env["DB_CONNECTION"]; conn != "" && db.Driver == def.Driver {
+		driver, ok := laravelDrivers[strings.ToLower(conn)]
+		if !ok {
+			return "", fmt.Errorf("%s 中的 DB_CONNECTION=%s 不受支持", name, conn)
+		}
+		db.Driver = driver
+	}
+	if host := env["DB_HOST"]; host != "" && db.Host == def.Host {
+		db.Host = host
+	}
+	if port := env["DB_PORT"]; port != "" && db.Port == def.Port {
+		p, err := strconv.Atoi(port)
+		if err != nil {
+			return "", fmt.Errorf("%s 中的 DB_PORT=%s 不是有效端口", name, port)
+		}
+		db.Port = p
+	}
+	if database := env["DB_DATABASE"]; database != "" && db.Database == def.Database {
+		if db.Driver == "sqlite" && !filepath.IsAbs(database) {
+			database = filepath.Join(sitePath, database)
+		}
+		db.Database = database
+	}
+	if user, ok := env["DB_USERNAME"]; ok && db.User == def.User {
+		db.User = user
+	}
+	if password, ok := env["DB_PASSWORD"]; ok && (db.Password == def.Password || db.Password == samplePassword) {
+		db.Password = password
+	}
+	if socket := env["DB_SOCKET"]; socket != "" && db.Socket == "" {
+		db.Socket = socket
+	}
+	if prefix := env["DB_PREFIX"]; prefix != "" && db.TablePrefix == "" {
+		db.TablePrefix = prefix
+	}
+
+	// dujiaoka 的上传文件位于 public/uploads，对外地址为 APP_URL/uploads
+	if appURL := env["APP_URL"]; appURL != "" {
+		return strings.TrimRight(appURL, "/") + "/uploads", nil
+	}
+	return "", nil
+}
@@ -0,0 +1,58 @@
+package config
+
+import (
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+// SourceConfig 多站点合并时的一个老版站点
+type SourceConfig struct {
+	Name           string   `yaml:"name"`            // 站点标识，写入 external_id 和状态文件名，如 shop-a
+	OldDB          DBConfig `yaml:"old_db"`          // 老版数据库，未填写的字段使用默认值
+	OldSitePath    string   `yaml:"old_site_path"`   // 老版站点路径（用于图片迁移）
+	SlugPrefix     string   `yaml:"slug_prefix"`     // slug 前缀，如 "a-"，避免不同站点的 slug 冲突
+	ParentCategory string   `yaml:"parent_category"` // 父分类名称，该站点的分类都放在其下，不存在时自动创建
+	CategoryPrefix string   `yaml:"category_prefix"` // 分类名称前缀，如 "[A站] "
+
+	CategoryMapping string `yaml:"category_mapping"` // 该站点的分类映射文件，覆盖 category.mapping_file
+}
+
+// UnmarshalYAML 解析前填充数据库默认值，未填写的字段与 old_db 的默认值一致
+func (s *SourceConfig) UnmarshalYAML(value *yaml.Node) error {
+	type plain SourceConfig
+	src := plain{OldDB: DefaultConfig().OldDB}
+	if err := value.Decode(&src); err != nil {
+		return err
+	}
+	*s = SourceConfig(src)
+	return nil
+}
+
+// SourceList 返回要迁移的老版站点
+// 未配置 sources 时返回由 old_db 和 options.old_site_path 组成的单个站点（名称为空）
+func (c *Config) SourceList() []SourceConfig {
+	if len(c.Sources) > 0 {
+		return c.Sources
+	}
+	return []SourceConfig{{
+		OldDB:       c.OldDB,
+		OldSitePath: c.Options.OldSitePath,
+	}}
+}
+
+// resolveSourcePasswordFiles 读取各站点 password_file 指定的密码
+func resolveSourcePasswordFiles(cfg *Config) error {
+	for i := range cfg.Sources {
+		db := &cfg.Sources[i].OldDB
+		if db.PasswordFile == "" {
+			continue
+		}
+		password, err := readSecretFile(db.PasswordFile)
+		if err != nil {
+			return fmt.Errorf("sources[%d].old_db.password_file: %w", i, err)
+		}
+		db.Password = password
+	}
+	return nil
+}
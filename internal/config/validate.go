@@ -0,0 +1,405 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/luoyanglang/dujiao-migrate/internal/utils"
+)
+
+var (
+	identPattern      = regexp.MustCompile(`^[A-Za-z0-9_]*$`)
+	sourceNamePattern = regexp.MustCompile(`^[a-z0-9_-]+$`)
+	pgSSLModes        = []string{"disable", "allow", "prefer", "require", "verify-ca", "verify-full"}
+	supportedDrivers  = []string{"mysql", "postgres", "sqlite"}
+	currencyPattern   = regexp.MustCompile(`^[A-Z]{3}$`)
+)
+
+// matchModes 判断已存在数据的方式
+var matchModes = []string{"external_id", "slug", "title"}
+
+// ValidationError 配置校验错误，包含全部问题
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "配置校验失败:\n  - " + strings.Join(e.Problems, "\n  - ")
+}
+
+// Validate 校验配置，返回所有问题及修改建议
+func (c *Config) Validate() error {
+	var problems []string
+	add := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	// 老版数据库
+	if len(c.Sources) == 0 {
+		validateDB(c.OldDB, "old_db", "options.old_site_path", c.Options.OldSitePath, add)
+	}
+	validateSources(c.Sources, add)
+
+	// 新版 API
+	if c.NewAPI.BaseURL == "" {
+		add("new_api.base_url 不能为空（--new-api），如 http://127.0.0.1:8080/api/v1/admin")
+	} else if err := validateHTTPURL(c.NewAPI.BaseURL); err != nil {
+		add("new_api.base_url=%q 无效: %v", c.NewAPI.BaseURL, err)
+	}
+	if c.NewAPI.Username == "" {
+		add("new_api.username 不能为空（--new-user）")
+	}
+	if c.NewAPI.Password == "" {
+		add("new_api.password 不能为空（--new-password 或 new_api.password_file）")
+	}
+
+	// 迁移选项
+	opts := c.Options
+	if opts.RetryTimes < 1 || opts.RetryTimes > 20 {
+		add("options.retry_times=%d 无效，应在 1-20 之间（1 表示不重试）", opts.RetryTimes)
+	}
+	if opts.RetryDelay < 0 || opts.RetryDelay > 300 {
+		add("options.retry_delay=%d 无效，应在 0-300 秒之间", opts.RetryDelay)
+	}
+	switch opts.OnExisting {
+	case "skip", "update", "duplicate":
+	default:
+		add("options.on_existing=%q 无效，可选值: skip, update, duplicate", opts.OnExisting)
+	}
+	if opts.OnExisting != "duplicate" && len(opts.MatchBy) == 0 {
+		add("options.match_by 不能为空，可选值: %s", strings.Join(matchModes, ", "))
+	}
+	for _, mode := range opts.MatchBy {
+		if !contains(matchModes, mode) {
+			add("options.match_by 包含无效值 %q，可选值: %s", mode, strings.Join(matchModes, ", "))
+		}
+	}
+	if opts.OnDeleted != "deactivate" && opts.OnDeleted != "delete" {
+		add("options.on_deleted=%q 无效，可选值: deactivate, delete", opts.OnDeleted)
+	}
+	if opts.MaxDeletions < 0 {
+		add("options.max_deletions=%d 无效，应大于等于 0（0 表示不限制）", opts.MaxDeletions)
+	}
+	if opts.BatchSize < 1 || opts.BatchSize > 10000 {
+		add("options.batch_size=%d 无效，应在 1-10000 之间（推荐 500）", opts.BatchSize)
+	}
+	if opts.ImageURLPrefix != "" {
+		if err := validateHTTPURL(opts.ImageURLPrefix); err != nil {
+			add("options.image_url_prefix=%q 无效: %v", opts.ImageURLPrefix, err)
+		}
+	}
+
+	// 多语言
+	switch c.I18n.ZhTW {
+	case "", "s2t", "s2tw", "s2twp", "s2hk":
+	default:
+		add("i18n.zh_tw=%q 无效，可选值: s2t, s2tw, s2twp, s2hk，留空不生成", c.I18n.ZhTW)
+	}
+	if c.I18n.ZhTWDict != "" {
+		if _, err := os.Stat(c.I18n.ZhTWDict); err != nil {
+			add("i18n.zh_tw_dict=%s 无法访问: %v", c.I18n.ZhTWDict, err)
+		}
+	}
+
+	switch c.I18n.EnUS {
+	case "", "none":
+	case "glossary":
+		if c.I18n.GlossaryFile == "" {
+			add("i18n.en_us=glossary 需要设置 i18n.glossary_file（CSV，每行 \"中文,英文\"）")
+		} else if _, err := os.Stat(c.I18n.GlossaryFile); err != nil {
+			add("i18n.glossary_file=%s 无法访问: %v", c.I18n.GlossaryFile, err)
+		}
+	case "http":
+		if err := validateHTTPURL(c.I18n.TranslateEndpoint); err != nil {
+			add("i18n.translate_endpoint=%q 无效: %v", c.I18n.TranslateEndpoint, err)
+		}
+	default:
+		add("i18n.en_us=%q 无效，可选值: glossary, http，留空不翻译", c.I18n.EnUS)
+	}
+
+	// slug
+	if !contains(utils.SlugStrategies, c.Slug.Strategy) {
+		add("slug.strategy=%q 无效，可选值: %s", c.Slug.Strategy, strings.Join(utils.SlugStrategies, ", "))
+	}
+	if c.Slug.MaxLength < 8 || c.Slug.MaxLength > 200 {
+		add("slug.max_length=%d 无效，应在 8-200 之间（默认 50）", c.Slug.MaxLength)
+	}
+	if c.Slug.Separator != "-" && c.Slug.Separator != "_" && c.Slug.Separator != "." {
+		add("slug.separator=%q 无效，可选值: - _ .", c.Slug.Separator)
+	}
+	if c.Slug.OverrideFile != "" {
+		if _, err := os.Stat(c.Slug.OverrideFile); err != nil {
+			add("slug.override_file=%s 无法访问: %v", c.Slug.OverrideFile, err)
+		}
+	}
+	if c.Slug.PinyinDict != "" {
+		if _, err := os.Stat(c.Slug.PinyinDict); err != nil {
+			add("slug.pinyin_dict=%s 无法访问: %v", c.Slug.PinyinDict, err)
+		}
+	}
+
+	// category
+	if c.Category.MappingFile != "" {
+		if _, err := os.Stat(c.Category.MappingFile); err != nil {
+			add("category.mapping_file=%s 无法访问: %v", c.Category.MappingFile, err)
+		}
+	}
+
+	// price
+	for _, item := range []struct{ key, value string }{
+		{"price.currency", c.Price.Currency},
+		{"price.source_currency", c.Price.SourceCurrency},
+	} {
+		if !currencyPattern.MatchString(item.value) {
+			add("%s=%q 无效，应为 3 位大写货币代码，如 CNY、USD", item.key, item.value)
+		}
+	}
+	if !contains(RoundingModes, c.Price.Rounding) {
+		add("price.rounding=%q 无效，可选值: %s", c.Price.Rounding, strings.Join(RoundingModes, ", "))
+	}
+	for currency, rate := range c.Price.Rates {
+		if f, err := strconv.ParseFloat(strings.TrimSpace(rate), 64); err != nil || f <= 0 {
+			add("price.rates.%s=%q 无效，应为大于 0 的数字", currency, rate)
+		}
+	}
+	if c.Price.RatesFile != "" {
+		if _, err := os.Stat(c.Price.RatesFile); err != nil {
+			add("price.rates_file=%s 无法访问: %v", c.Price.RatesFile, err)
+		}
+	} else if c.Price.Currency != c.Price.SourceCurrency {
+		if _, ok := c.Price.Rates[c.Price.Currency]; !ok {
+			add("price.currency=%s 与 price.source_currency=%s 不同，需要在 price.rates 或 price.rates_file 中提供汇率",
+				c.Price.Currency, c.Price.SourceCurrency)
+		}
+	}
+
+	// fulfillment
+	for oldType, newType := range c.Fulfillment.Types {
+		if _, err := strconv.Atoi(oldType); err != nil {
+			add("fulfillment.types 的键 %q 无效，应为老版商品类型数字，如 1、2", oldType)
+		}
+		if !contains(FulfillmentTypes, newType) {
+			add("fulfillment.types.%s=%q 无效，可选值: %s", oldType, newType, strings.Join(FulfillmentTypes, ", "))
+		}
+	}
+	switch c.Fulfillment.APIHook {
+	case "webhook":
+		if c.Fulfillment.WebhookField == "" || !identPattern.MatchString(c.Fulfillment.WebhookField) {
+			add("fulfillment.webhook_field=%q 无效，应为新版商品的字段名", c.Fulfillment.WebhookField)
+		}
+	case "ignore":
+	default:
+		add("fulfillment.api_hook=%q 无效，可选值: webhook, ignore", c.Fulfillment.APIHook)
+	}
+
+	// fields
+	if c.Fields.Script != "" {
+		if _, err := os.Stat(c.Fields.Script); err != nil {
+			add("fields.script=%s 无法访问: %v", c.Fields.Script, err)
+		}
+	}
+	for _, name := range sortedRuleNames(c.Fields.Products) {
+		rule := c.Fields.Products[name]
+		if contains(managedFields, name) {
+			add("fields.products.%s 由迁移工具生成，不能通过字段规则设置", name)
+		}
+		set := 0
+		for _, ok := range []bool{rule.Column != "", rule.Value != nil, rule.Template != ""} {
+			if ok {
+				set++
+			}
+		}
+		if set != 1 {
+			add("fields.products.%s: column、value、template 必须且只能设置一个", name)
+		}
+		if rule.Column != "" && !identPattern.MatchString(rule.Column) {
+			add("fields.products.%s.column=%q 无效，应为老版商品表的列名", name, rule.Column)
+		}
+		if rule.Type != "" && !contains(FieldTypes, rule.Type) {
+			add("fields.products.%s.type=%q 无效，可选值: %s", name, rule.Type, strings.Join(FieldTypes, ", "))
+		}
+	}
+
+	// filter
+	if _, err := utils.ParseIDList(c.Filter.Categories); err != nil {
+		add("filter.categories=%q 无效: %v", c.Filter.Categories, err)
+	}
+	if _, err := utils.ParseIDList(c.Filter.Products); err != nil {
+		add("filter.products=%q 无效: %v", c.Filter.Products, err)
+	}
+	if strings.Contains(c.Filter.Where, ";") {
+		add("filter.where 不能包含分号，只能写一个查询条件")
+	}
+	if c.Filter.NamePattern != "" {
+		if _, err := regexp.Compile(c.Filter.NamePattern); err != nil {
+			add("filter.name_pattern=%q 不是有效的正则表达式: %v", c.Filter.NamePattern, err)
+		}
+	}
+	if c.Filter.CreatedAfter != "" {
+		if _, err := utils.ParseDate(c.Filter.CreatedAfter); err != nil {
+			add("filter.created_after=%q 无效，格式如 2024-01-01 或 2024-01-01 08:00:00", c.Filter.CreatedAfter)
+		}
+	}
+
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
+
+// validateDB 校验老版数据库和站点路径配置，key 为数据库配置项路径（如 old_db），siteKey 为站点路径配置项
+func validateDB(db DBConfig, key, siteKey, sitePath string, add func(format string, args ...interface{})) {
+	// 命令行参数只作用于 old_db
+	hint := func(flag string) string {
+		if key != "old_db" {
+			return ""
+		}
+		return "（--" + flag + "）"
+	}
+
+	switch {
+	case db.DSN != "" && contains(supportedDrivers, db.Driver):
+		// 使用完整 DSN 时不再校验单独的连接字段
+	case db.Driver == "mysql" || db.Driver == "postgres":
+		if db.Host == "" && db.Socket == "" {
+			add("%s.host 不能为空%s，或设置 %s.socket 通过 Unix socket 连接", key, hint("old-host"), key)
+		}
+		if db.Socket == "" && (db.Port < 1 || db.Port > 65535) {
+			add("%s.port=%d 无效，应在 1-65535 之间（MySQL 默认 3306，PostgreSQL 默认 5432）", key, db.Port)
+		}
+		if db.User == "" {
+			add("%s.user 不能为空%s", key, hint("old-user"))
+		}
+		if db.Database == "" {
+			add("%s.database 不能为空%s", key, hint("old-database"))
+		}
+	case db.Driver == "sqlite":
+		if db.Database == "" {
+			add("%s.database 不能为空，SQLite 需填写数据库文件路径", key)
+		} else if _, err := os.Stat(db.Database); err != nil {
+			add("%s.database=%s 无法访问: %v", key, db.Database, err)
+		}
+	default:
+		add("%s.driver=%q 不受支持，可选值: %s", key, db.Driver, strings.Join(supportedDrivers, ", "))
+	}
+
+	if db.Driver == "mysql" && !identPattern.MatchString(db.Charset) {
+		add("%s.charset=%q 无效，应为字符集名称，如 utf8mb4、utf8、latin1", key, db.Charset)
+	}
+	if db.Socket != "" {
+		if _, err := os.Stat(db.Socket); err != nil {
+			add("%s.socket=%s 无法访问: %v", key, db.Socket, err)
+		}
+	}
+	switch db.TLS {
+	case "", "true", "false", "skip-verify", "preferred":
+	default:
+		if db.Driver != "mysql" {
+			add("%s.tls 仅适用于 MySQL，PostgreSQL 请使用 ssl_mode", key)
+		} else if _, err := os.Stat(db.TLS); err != nil {
+			add("%s.tls=%q 无效，可选值: true, false, skip-verify, preferred 或 CA 证书路径", key, db.TLS)
+		}
+	}
+	if db.Driver == "postgres" && !contains(pgSSLModes, db.SSLMode) {
+		add("%s.ssl_mode=%q 无效，可选值: %s", key, db.SSLMode, strings.Join(pgSSLModes, ", "))
+	}
+	if !identPattern.MatchString(db.TablePrefix) {
+		add("%s.table_prefix=%q 无效，只能包含字母、数字和下划线", key, db.TablePrefix)
+	}
+
+	// SSH 隧道
+	if ssh := db.SSH; ssh.Enabled() {
+		if db.Driver == "sqlite" {
+			add("%s.ssh 不支持 SQLite，请将数据库文件复制到本机", key)
+		}
+		if ssh.Port < 1 || ssh.Port > 65535 {
+			add("%s.ssh.port=%d 无效，应在 1-65535 之间（默认 22）", key, ssh.Port)
+		}
+		if ssh.User == "" {
+			add("%s.ssh.user 不能为空", key)
+		}
+		if ssh.KeyFile == "" && ssh.Password == "" {
+			add("%s.ssh 需要设置 key_file 或 password", key)
+		}
+		if ssh.SFTP && sitePath == "" {
+			add("%s.ssh.sftp 已启用，但未设置 %s（老版服务器上的站点路径）", key, siteKey)
+		}
+	} else if ssh.SFTP {
+		add("%s.ssh.sftp 需要同时设置 %s.ssh.host", key, key)
+	}
+
+	// 通过 SFTP 读取时站点路径在远程服务器上，无法在本地检查
+	if sitePath != "" && !db.SSH.SFTP {
+		if info, err := os.Stat(sitePath); err != nil {
+			add("%s=%s 无法访问: %v", siteKey, sitePath, err)
+		} else if !info.IsDir() {
+			add("%s=%s 不是目录", siteKey, sitePath)
+		}
+	}
+}
+
+// validateSources 校验多站点配置
+func validateSources(sources []SourceConfig, add func(format string, args ...interface{})) {
+	names := make(map[string]bool, len(sources))
+	for i, src := range sources {
+		key := fmt.Sprintf("sources[%d]", i)
+		switch {
+		case src.Name == "":
+			add("%s.name 不能为空，用于区分不同站点的数据", key)
+		case !sourceNamePattern.MatchString(src.Name):
+			add("%s.name=%q 无效，只能包含小写字母、数字、- 和 _", key, src.Name)
+		case names[src.Name]:
+			add("%s.name=%q 重复", key, src.Name)
+		}
+		names[src.Name] = true
+
+		if src.SlugPrefix != "" && !sourceNamePattern.MatchString(src.SlugPrefix) {
+			add("%s.slug_prefix=%q 无效，只能包含小写字母、数字、- 和 _", key, src.SlugPrefix)
+		}
+		if src.CategoryMapping != "" {
+			if _, err := os.Stat(src.CategoryMapping); err != nil {
+				add("%s.category_mapping=%s 无法访问: %v", key, src.CategoryMapping, err)
+			}
+		}
+		validateDB(src.OldDB, key+".old_db", key+".old_site_path", src.OldSitePath, add)
+	}
+}
+
+// validateHTTPURL 校验 http/https 地址
+func validateHTTPURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("必须以 http:// 或 https:// 开头")
+	}
+	if u.Host == "" {
+		return fmt.Errorf("缺少主机名")
+	}
+	return nil
+}
+
+// sortedRuleNames 按字段名排序，保证校验结果顺序稳定
+func sortedRuleNames(rules map[string]FieldRule) []string {
+	names := make([]string, 0, len(rules))
+	for name := range rules {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -1,45 +1,226 @@
 package database
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"database/sql"
 	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
 
-	_ "github.com/go-sql-driver/mysql"
-	_ "github.com/lib/pq"
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
 	_ "github.com/mattn/go-sqlite3"
+	"golang.org/x/crypto/ssh"
 
 	"github.com/luoyanglang/dujiao-migrate/internal/config"
 )
 
-// Connect 连接数据库
-func Connect(cfg config.DBConfig) (*sql.DB, error) {
-	var dsn string
+// customTLSName 自定义 CA 证书注册到 MySQL 驱动时使用的名称
+const customTLSName = "dujiao-migrate"
+
+// Connect 连接数据库，tunnel 不为空时通过 SSH 隧道连接
+func Connect(cfg config.DBConfig, tunnel *ssh.Client) (*sql.DB, error) {
+	dsn, err := BuildDSN(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	var db *sql.DB
+	if tunnel != nil {
+		db, err = openTunneled(cfg.Driver, dsn, tunnel)
+	} else {
+		db, err = sql.Open(sqlDriverName(cfg.Driver), dsn)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("打开数据库连接失败: %w", err)
+	}
+
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("数据库连接测试失败: %w", err)
+	}
+
+	return db, nil
+}
+
+// openTunneled 打开经由 SSH 隧道的数据库连接
+func openTunneled(driver, dsn string, tunnel *ssh.Client) (*sql.DB, error) {
+	dialer := sshDialer{client: tunnel}
+
+	switch driver {
+	case "mysql":
+		mc, err := mysql.ParseDSN(dsn)
+		if err != nil {
+			return nil, err
+		}
+		// 每个隧道注册独立的网络名，原网络类型（tcp/unix）在远端拨号时使用
+		network := mc.Net
+		name := fmt.Sprintf("ssh-%p", tunnel)
+		mysql.RegisterDialContext(name, func(ctx context.Context, addr string) (net.Conn, error) {
+			return dialer.DialContext(ctx, network, addr)
+		})
+		mc.Net = name
+		return sql.Open("mysql", mc.FormatDSN())
+
+	case "postgres":
+		connector, err := pq.NewConnector(dsn)
+		if err != nil {
+			return nil, err
+		}
+		connector.Dialer(dialer)
+		return sql.OpenDB(connector), nil
+
+	default:
+		return nil, fmt.Errorf("%s 不支持 SSH 隧道", driver)
+	}
+}
+
+// BuildDSN 根据配置生成数据源名称，设置了 dsn 时直接使用
+func BuildDSN(cfg config.DBConfig) (string, error) {
+	if cfg.DSN != "" {
+		return cfg.DSN, nil
+	}
 
 	switch cfg.Driver {
 	case "mysql":
-		dsn = fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?charset=utf8mb4&parseTime=true",
-			cfg.User, cfg.Password, cfg.Host, cfg.Port, cfg.Database)
+		return mysqlDSN(cfg)
 
 	case "postgres":
-		dsn = fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
-			cfg.Host, cfg.Port, cfg.User, cfg.Password, cfg.Database, cfg.SSLMode)
+		host := cfg.Host
+		if cfg.Socket != "" {
+			// lib/pq 中以 / 开头的 host 表示 Unix socket 所在目录
+			host = cfg.Socket
+		}
+		parts := []string{
+			"host=" + pgQuote(host),
+			"port=" + strconv.Itoa(cfg.Port),
+			"user=" + pgQuote(cfg.User),
+			"password=" + pgQuote(cfg.Password),
+			"dbname=" + pgQuote(cfg.Database),
+			"sslmode=" + pgQuote(cfg.SSLMode),
+		}
+		for _, key := range sortedKeys(cfg.Params) {
+			parts = append(parts, key+"="+pgQuote(cfg.Params[key]))
+		}
+		return strings.Join(parts, " "), nil
 
 	case "sqlite":
-		dsn = cfg.Database
+		if len(cfg.Params) == 0 {
+			return cfg.Database, nil
+		}
+		values := url.Values{}
+		for key, value := range cfg.Params {
+			values.Set(key, value)
+		}
+		return "file:" + cfg.Database + "?" + values.Encode(), nil
+
+	default:
+		return "", fmt.Errorf("不支持的数据库驱动: %s", cfg.Driver)
+	}
+}
+
+// sqlDriverName 配置中的驱动名对应的 database/sql 驱动名
+func sqlDriverName(driver string) string {
+	if driver == "sqlite" {
+		return "sqlite3" // mattn/go-sqlite3 注册的名称
+	}
+	return driver
+}
+
+// mysqlDSN 生成 MySQL DSN，连接字符集取自 charset 配置
+func mysqlDSN(cfg config.DBConfig) (string, error) {
+	mc := mysql.NewConfig()
+	mc.User = cfg.User
+	mc.Passwd = cfg.Password
+	mc.DBName = cfg.Database
+	mc.ParseTime = true
 
+	if cfg.Socket != "" {
+		mc.Net = "unix"
+		mc.Addr = cfg.Socket
+	} else {
+		mc.Net = "tcp"
+		mc.Addr = net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port))
+	}
+
+	charset := cfg.Charset
+	if charset == "" {
+		charset = "utf8mb4"
+	}
+	mc.Params = map[string]string{"charset": charset}
+
+	switch cfg.TLS {
+	case "", "false":
+	case "true", "skip-verify", "preferred":
+		mc.TLSConfig = cfg.TLS
 	default:
-		return nil, fmt.Errorf("不支持的数据库驱动: %s", cfg.Driver)
+		// 其他值视为 CA 证书路径
+		if err := registerCustomCA(cfg.TLS, cfg.Host); err != nil {
+			return "", err
+		}
+		mc.TLSConfig = customTLSName
+	}
+
+	dsn := mc.FormatDSN()
+
+	// 附加参数交给驱动解析，超时等参数会被正确识别而不是作为会话变量发送
+	if len(cfg.Params) > 0 {
+		values := url.Values{}
+		for key, value := range cfg.Params {
+			values.Set(key, value)
+		}
+		sep := "?"
+		if strings.Contains(dsn, "?") {
+			sep = "&"
+		}
+		dsn += sep + values.Encode()
 	}
 
-	db, err := sql.Open(cfg.Driver, dsn)
+	if _, err := mysql.ParseDSN(dsn); err != nil {
+		return "", fmt.Errorf("MySQL 连接参数无效: %w", err)
+	}
+	return dsn, nil
+}
+
+// registerCustomCA 加载 CA 证书并注册为 MySQL TLS 配置
+func registerCustomCA(caFile, serverName string) error {
+	pem, err := os.ReadFile(caFile)
 	if err != nil {
-		return nil, fmt.Errorf("打开数据库连接失败: %w", err)
+		return fmt.Errorf("读取 TLS CA 证书失败: %w", err)
 	}
 
-	if err := db.Ping(); err != nil {
-		db.Close()
-		return nil, fmt.Errorf("数据库连接测试失败: %w", err)
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return fmt.Errorf("TLS CA 证书格式错误: %s", caFile)
 	}
 
-	return db, nil
+	return mysql.RegisterTLSConfig(customTLSName, &tls.Config{
+		RootCAs:    pool,
+		ServerName: serverName,
+	})
+}
+
+// pgQuote 按 libpq 连接串规则为值加引号
+func pgQuote(value string) string {
+	if value != "" && !strings.ContainsAny(value, ` '\`) {
+		return value
+	}
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, `'`, `\'`)
+	return "'" + value + "'"
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
 }
@@ -0,0 +1,256 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// fieldSpec 逻辑字段定义
+type fieldSpec struct {
+	Name       string   // 逻辑字段名
+	Candidates []string // 候选列名，按优先级排列
+	Required   bool     // 是否必需
+	Default    string   // 缺失时使用的 SQL 默认值
+}
+
+// tableSpecs 老版数据表结构定义（逻辑表名 -> 字段列表）
+// 字段按顺序解析，已被前面字段占用的列不会被重复使用
+var tableSpecs = map[string][]fieldSpec{
+	"goods_group": {
+		{Name: "id", Candidates: []string{"id"}, Required: true},
+		{Name: "name", Candidates: []string{"gp_name", "name", "title"}, Required: true},
+		{Name: "ord", Candidates: []string{"ord", "sort", "sort_order"}, Default: "0"},
+		{Name: "is_open", Candidates: []string{"is_open", "status"}, Default: "1"},
+		{Name: "created_at", Candidates: []string{"created_at"}, Default: "NULL"},
+		{Name: "updated_at", Candidates: []string{"updated_at"}, Default: "NULL"},
+		{Name: "deleted_at", Candidates: []string{"deleted_at"}, Default: "NULL"},
+	},
+	"goods": {
+		{Name: "id", Candidates: []string{"id"}, Required: true},
+		{Name: "group_id", Candidates: []string{"group_id", "category_id", "gp_id"}, Required: true},
+		{Name: "name", Candidates: []string{"gd_name", "name", "title"}, Required: true},
+		{Name: "content", Candidates: []string{"content", "description"}, Default: "NULL"},
+		{Name: "description", Candidates: []string{"gd_description", "summary", "description"}, Default: "NULL"},
+		{Name: "keywords", Candidates: []string{"gd_keywords", "keywords"}, Default: "NULL"},
+		{Name: "picture", Candidates: []string{"picture", "image", "img"}, Default: "NULL"},
+		{Name: "actual_price", Candidates: []string{"actual_price", "price"}, Required: true},
+		{Name: "in_stock", Candidates: []string{"in_stock", "stock"}, Default: "0"},
+		{Name: "ord", Candidates: []string{"ord", "sort", "sort_order"}, Default: "0"},
+		{Name: "type", Candidates: []string{"type"}, Default: "1"},
+		{Name: "other_ipu_cnf", Candidates: []string{"other_ipu_cnf"}, Default: "NULL"},
+		{Name: "api_hook", Candidates: []string{"api_hook"}, Default: "NULL"},
+		{Name: "is_open", Candidates: []string{"is_open", "status"}, Default: "1"},
+		{Name: "created_at", Candidates: []string{"created_at"}, Default: "NULL"},
+		{Name: "updated_at", Candidates: []string{"updated_at"}, Default: "NULL"},
+		{Name: "deleted_at", Candidates: []string{"deleted_at"}, Default: "NULL"},
+	},
+	"carmis": {
+		{Name: "id", Candidates: []string{"id"}, Required: true},
+		{Name: "goods_id", Candidates: []string{"goods_id"}, Required: true},
+		{Name: "carmi", Candidates: []string{"carmi", "card", "secret"}, Required: true},
+		{Name: "status", Candidates: []string{"status"}, Default: "1"},
+		{Name: "created_at", Candidates: []string{"created_at"}, Default: "NULL"},
+		{Name: "updated_at", Candidates: []string{"updated_at"}, Default: "NULL"},
+		{Name: "deleted_at", Candidates: []string{"deleted_at"}, Default: "NULL"},
+	},
+}
+
+// tableOrder 数据表检测顺序
+var tableOrder = []string{"goods_group", "goods", "carmis"}
+
+// Schema 老版数据库结构（表前缀与字段映射）
+type Schema struct {
+	Driver  string
+	Prefix  string
+	Variant string
+
+	columns map[string]map[string]string // 逻辑表名 -> 逻辑字段 -> 实际列名
+}
+
+// DetectSchema 通过 information_schema 检测老版数据库结构
+// 缺少必需字段时返回包含全部问题的错误
+func DetectSchema(db *sql.DB, driver, prefix string) (*Schema, error) {
+	s := &Schema{
+		Driver:  driver,
+		Prefix:  prefix,
+		columns: make(map[string]map[string]string),
+	}
+
+	var problems []string
+	for _, table := range tableOrder {
+		actual, err := listColumns(db, driver, prefix+table)
+		if err != nil {
+			return nil, fmt.Errorf("读取数据表 %s 结构失败: %w", prefix+table, err)
+		}
+		if len(actual) == 0 {
+			problems = append(problems, fmt.Sprintf("未找到数据表 %s（请检查 table_prefix 配置）", prefix+table))
+			continue
+		}
+
+		mapping := make(map[string]string)
+		used := make(map[string]bool)
+		for _, field := range tableSpecs[table] {
+			for _, candidate := range field.Candidates {
+				if actual[candidate] && !used[candidate] {
+					mapping[field.Name] = candidate
+					used[candidate] = true
+					break
+				}
+			}
+			if _, ok := mapping[field.Name]; !ok && field.Required {
+				problems = append(problems, fmt.Sprintf("数据表 %s 缺少必需字段 %s（候选列名: %s）",
+					prefix+table, field.Name, strings.Join(field.Candidates, ", ")))
+			}
+		}
+		s.columns[table] = mapping
+	}
+
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("老版数据库结构不兼容:\n  - %s", strings.Join(problems, "\n  - "))
+	}
+
+	s.Variant = s.detectVariant()
+	return s, nil
+}
+
+// detectVariant 根据字段命名判断 dujiaoka 版本/变体
+func (s *Schema) detectVariant() string {
+	if s.columns["goods_group"]["name"] == "gp_name" && s.columns["goods"]["name"] == "gd_name" {
+		if s.Has("goods", "other_ipu_cnf") && s.Has("goods", "type") {
+			return "dujiaoka 2.x 标准版"
+		}
+		return "dujiaoka 早期版本"
+	}
+	return "dujiaoka 二开变体（字段已重命名）"
+}
+
+// Table 返回带前缀的实际表名
+func (s *Schema) Table(name string) string {
+	return s.Prefix + name
+}
+
+// Has 判断逻辑字段在老版数据库中是否存在
+func (s *Schema) Has(table, field string) bool {
+	_, ok := s.columns[table][field]
+	return ok
+}
+
+// Col 返回逻辑字段对应的列名，缺失时返回默认值表达式
+func (s *Schema) Col(table, field string) string {
+	if col, ok := s.columns[table][field]; ok {
+		return col
+	}
+	for _, spec := range tableSpecs[table] {
+		if spec.Name == field && spec.Default != "" {
+			return spec.Default
+		}
+	}
+	return "NULL"
+}
+
+// Cols 返回多个逻辑字段的列表达式，用逗号连接
+func (s *Schema) Cols(table string, fields ...string) string {
+	cols := make([]string, len(fields))
+	for i, field := range fields {
+		cols[i] = s.Col(table, field)
+	}
+	return strings.Join(cols, ", ")
+}
+
+// OrderBy 返回排序字段，缺少排序字段时按 ID 排序
+func (s *Schema) OrderBy(table string) string {
+	if s.Has(table, "ord") {
+		return s.Col(table, "ord")
+	}
+	return s.Col(table, "id")
+}
+
+// Rebind 将 ? 占位符转换为当前驱动的占位符格式
+func (s *Schema) Rebind(query string) string {
+	if s.Driver != "postgres" {
+		return query
+	}
+
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// TimeArg 时间查询参数；SQLite 按文本比较时间，转换为与 Laravel 写入格式相同的字符串，
+// 否则 time.Time 绑定为带时区的字符串，同一秒内的数据会比检查点小
+func (s *Schema) TimeArg(t time.Time) interface{} {
+	if s.Driver == "sqlite" {
+		return t.UTC().Format("2006-01-02 15:04:05")
+	}
+	return t
+}
+
+// listColumns 列出数据表的全部列名
+func listColumns(db *sql.DB, driver, table string) (map[string]bool, error) {
+	var (
+		rows *sql.Rows
+		err  error
+	)
+
+	switch driver {
+	case "mysql":
+		rows, err = db.Query("SELECT COLUMN_NAME FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ?", table)
+	case "postgres":
+		rows, err = db.Query("SELECT column_name FROM information_schema.columns WHERE table_schema = current_schema() AND table_name = $1", table)
+	case "sqlite":
+		return listSQLiteColumns(db, table)
+	default:
+		return nil, fmt.Errorf("不支持的数据库驱动: %s", driver)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		columns[strings.ToLower(name)] = true
+	}
+	return columns, rows.Err()
+}
+
+// listSQLiteColumns 通过 PRAGMA table_info 列出 SQLite 表的列名
+func listSQLiteColumns(db *sql.DB, table string) (map[string]bool, error) {
+	rows, err := db.Query(fmt.Sprintf("PRAGMA table_info(%q)", table))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns := make(map[string]bool)
+	for rows.Next() {
+		var (
+			cid       int
+			name      string
+			colType   string
+			notNull   int
+			dfltValue sql.NullString
+			pk        int
+		)
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &dfltValue, &pk); err != nil {
+			return nil, err
+		}
+		columns[strings.ToLower(name)] = true
+	}
+	return columns, rows.Err()
+}
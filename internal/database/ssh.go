@@ -0,0 +1,106 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+
+	"github.com/luoyanglang/dujiao-migrate/internal/config"
+)
+
+// DialSSH 建立 SSH 连接，用于数据库隧道和 SFTP
+func DialSSH(cfg config.SSHConfig) (*ssh.Client, error) {
+	var auths []ssh.AuthMethod
+
+	if cfg.KeyFile != "" {
+		key, err := os.ReadFile(expandHome(cfg.KeyFile))
+		if err != nil {
+			return nil, fmt.Errorf("读取 SSH 私钥失败: %w", err)
+		}
+
+		var signer ssh.Signer
+		if cfg.Passphrase != "" {
+			signer, err = ssh.ParsePrivateKeyWithPassphrase(key, []byte(cfg.Passphrase))
+		} else {
+			signer, err = ssh.ParsePrivateKey(key)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("解析 SSH 私钥失败: %w", err)
+		}
+		auths = append(auths, ssh.PublicKeys(signer))
+	}
+	if cfg.Password != "" {
+		auths = append(auths, ssh.Password(cfg.Password))
+	}
+	if len(auths) == 0 {
+		return nil, fmt.Errorf("SSH 未配置认证方式，请设置 key_file 或 password")
+	}
+
+	hostKeyCallback := ssh.InsecureIgnoreHostKey()
+	if !cfg.InsecureSkipHostKey {
+		knownHostsFile := cfg.KnownHosts
+		if knownHostsFile == "" {
+			knownHostsFile = "~/.ssh/known_hosts"
+		}
+		callback, err := knownhosts.New(expandHome(knownHostsFile))
+		if err != nil {
+			return nil, fmt.Errorf("读取 known_hosts 失败: %w", err)
+		}
+		hostKeyCallback = callback
+	}
+
+	port := cfg.Port
+	if port == 0 {
+		port = 22
+	}
+	addr := net.JoinHostPort(cfg.Host, strconv.Itoa(port))
+
+	client, err := ssh.Dial("tcp", addr, &ssh.ClientConfig{
+		User:            cfg.User,
+		Auth:            auths,
+		HostKeyCallback: hostKeyCallback,
+		Timeout:         15 * time.Second,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("SSH 连接 %s 失败: %w", addr, err)
+	}
+
+	return client, nil
+}
+
+// sshDialer 通过 SSH 连接转发数据库连接，实现 pq.Dialer
+type sshDialer struct {
+	client *ssh.Client
+}
+
+func (d sshDialer) Dial(network, address string) (net.Conn, error) {
+	return d.client.Dial(network, address)
+}
+
+func (d sshDialer) DialTimeout(network, address string, timeout time.Duration) (net.Conn, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return d.DialContext(ctx, network, address)
+}
+
+func (d sshDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	return d.client.DialContext(ctx, network, address)
+}
+
+// expandHome 展开路径开头的 ~
+func expandHome(path string) string {
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, path[1:])
+		}
+	}
+	return path
+}
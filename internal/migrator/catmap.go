@@ -0,0 +1,301 @@
+package migrator
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/luoyanglang/dujiao-migrate/internal/models"
+	"github.com/luoyanglang/dujiao-migrate/internal/utils"
+)
+
+// categoryRule 一个老版分类的映射规则，name、slug、id、drop 只能设置一个
+type categoryRule struct {
+	Name string `yaml:"name"` // 改名；多个老版分类使用同一名称时合并为一个新版分类
+	Slug string `yaml:"slug"` // 映射到新版已有分类（按 slug）
+	ID   int    `yaml:"id"`   // 映射到新版已有分类（按 ID）
+	Drop bool   `yaml:"drop"` // 不迁移，商品归入默认分类
+
+	Parent string `yaml:"parent"` // 上级分类路径，如 游戏/PC，可与 name 同时设置
+}
+
+// UnmarshalYAML 支持简写: "3": 游戏 等同 "3": {name: 游戏}
+func (r *categoryRule) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		r.Name = strings.TrimSpace(value.Value)
+		return nil
+	}
+	type plain categoryRule
+	return value.Decode((*plain)(r))
+}
+
+func (r categoryRule) validate() error {
+	set := 0
+	for _, ok := range []bool{r.Name != "", r.Slug != "", r.ID > 0, r.Drop} {
+		if ok {
+			set++
+		}
+	}
+	switch {
+	case r.Parent != "" && set > 0 && r.Name == "":
+		return fmt.Errorf("parent 只能与 name 同时设置")
+	case r.Parent == "" && set != 1:
+		return fmt.Errorf("name、slug、id、drop 必须且只能设置一个")
+	case set > 1:
+		return fmt.Errorf("name、slug、id、drop 只能设置一个")
+	}
+	return nil
+}
+
+// categoryMapping 分类映射文件
+type categoryMapping struct {
+	Default    *categoryRule           `yaml:"default"`    // 不迁移的分类下的商品归入此分类
+	Categories map[string]categoryRule `yaml:"categories"` // 老版分类 ID 或名称 -> 规则
+}
+
+// loadCategoryMapping 加载分类映射文件，path 为空时返回空映射
+//
+//	default: 其他                # 不存在时自动创建，也可写 {slug: other} 或 {id: 12}
+//	categories:
+//	  "3": 游戏                  # 按老版 ID 改名
+//	  "手游充值": 游戏            # 按名称，与上一条合并为一个分类
+//	  "5": {slug: software}     # 映射到新版已有分类
+//	  "6": {id: 12}
+//	  "7": {drop: true}         # 不迁移，商品归入 default
+//	  "8": {parent: 影音/会员}   # 放到上级分类下，上级分类不存在时自动创建
+func loadCategoryMapping(path string) (*categoryMapping, error) {
+	mapping := &categoryMapping{}
+	if path == "" {
+		return mapping, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取分类映射文件失败: %w", err)
+	}
+	if err := yaml.Unmarshal(data, mapping); err != nil {
+		return nil, fmt.Errorf("解析分类映射文件失败: %w", err)
+	}
+
+	if mapping.Default != nil {
+		if err := mapping.Default.validate(); err != nil {
+			return nil, fmt.Errorf("分类映射文件 default: %w", err)
+		}
+		if mapping.Default.Drop {
+			return nil, fmt.Errorf("分类映射文件 default 不能设置 drop")
+		}
+	}
+	hasDrop := false
+	for key, rule := range mapping.Categories {
+		if err := rule.validate(); err != nil {
+			return nil, fmt.Errorf("分类映射文件 %q: %w", key, err)
+		}
+		hasDrop = hasDrop || rule.Drop
+	}
+	if hasDrop && mapping.Default == nil {
+		log.Println("警告: 分类映射文件中有 drop 的分类但未设置 default，这些分类下的商品不会迁移")
+	}
+
+	return mapping, nil
+}
+
+// lookup 查找老版分类的规则，按 ID 优先，其次按名称
+func (cm *categoryMapping) lookup(id int, name string) (categoryRule, bool) {
+	if rule, ok := cm.Categories[strconv.Itoa(id)]; ok {
+		return rule, true
+	}
+	rule, ok := cm.Categories[strings.TrimSpace(name)]
+	return rule, ok
+}
+
+// mappedExternalID 按名称合并的分类和自动创建的上级分类的标记，如 dujiaoka:category:游戏/Steam，不随老版分类变化
+func (src *source) mappedExternalID(name string) string {
+	if src.conf.Name == "" {
+		return "dujiaoka:category:" + name
+	}
+	return fmt.Sprintf("dujiaoka:%s:category:%s", src.conf.Name, name)
+}
+
+// resolveExisting 按 slug 或 ID 查找规则指向的新版已有分类
+func resolveExisting(existing *existingIndex, rule categoryRule) (int, bool) {
+	if rule.ID > 0 {
+		_, ok := existing.byID[rule.ID]
+		return rule.ID, ok
+	}
+	item, ok := existing.bySlug[rule.Slug]
+	return item.ID, ok
+}
+
+// defaultCategory 返回不迁移的分类下商品归入的新版分类，第一次使用时查找或创建
+func (m *Migrator) defaultCategory(existing *existingIndex, usedSlugs map[string]bool) (int, error) {
+	rule := m.categoryMapping.Default
+	if rule == nil {
+		return 0, nil
+	}
+	if m.defaultID > 0 {
+		return m.defaultID, nil
+	}
+
+	if rule.Name == "" {
+		id, ok := resolveExisting(existing, *rule)
+		if !ok {
+			return 0, fmt.Errorf("默认分类不存在 (slug: %q, ID: %d)", rule.Slug, rule.ID)
+		}
+		m.defaultID = id
+		return id, nil
+	}
+
+	parents, leaf := m.splitCategoryPath(rule.Name, "")
+	id, err := m.ensureCategoryPath(existing, usedSlugs, append(parents, leaf))
+	if err != nil {
+		return 0, fmt.Errorf("创建默认分类 %s 失败: %w", rule.Name, err)
+	}
+	m.defaultID = id
+	return id, nil
+}
+
+// ensureCategory 按标记或同一上级下的名称查找新版分类，不存在时创建，返回新版分类 ID
+func (m *Migrator) ensureCategory(existing *existingIndex, usedSlugs map[string]bool, name, extID string, parentID int) (int, error) {
+	if item, _, ok := existing.match([]string{matchExternalID}, extID, "", name); ok {
+		return item.ID, nil
+	}
+	if item, ok := existing.childByTitle(name, parentID, extID); ok {
+		return item.ID, nil
+	}
+
+	slug := m.conf.SlugPrefix + m.slugger.Make(name, kindCategory, 0)
+	payload := map[string]interface{}{
+		"id":          0,
+		"name":        m.localize(name),
+		"slug":        utils.EnsureUniqueSlug(slug, m.cfg.Slug.Separator, usedSlugs),
+		"sort_order":  0,
+		"external_id": extID,
+	}
+	if parentID > 0 {
+		payload["parent_id"] = parentID
+	}
+	newID, err := m.createWithSlugRetry("/categories", payload, slug, usedSlugs)
+	if err != nil {
+		return 0, err
+	}
+	log.Printf("  ✓ %s (新ID:%d)", name, newID)
+	m.stats.Categories.Success++
+	return newID, nil
+}
+
+// childByTitle 按名称查找上级为 parentID 的新版分类，同名多条时取 ID 最小的；
+// 带有其他老版 ID 标记的分类视为不同分类
+func (idx *existingIndex) childByTitle(name string, parentID int, extID string) (existingItem, bool) {
+	key := normalizeTitle(name)
+	if key == "" {
+		return existingItem{}, false
+	}
+
+	var found existingItem
+	ok := false
+	for _, item := range idx.byID {
+		if toInt(item.Data["parent_id"]) != parentID || (ok && item.ID > found.ID) {
+			continue
+		}
+		if item.ExternalID != "" && item.ExternalID != extID {
+			continue
+		}
+		for _, title := range titles(item.Data["name"]) {
+			if normalizeTitle(title) == key {
+				found, ok = item, true
+				break
+			}
+		}
+	}
+	return found, ok
+}
+
+// applyCategoryRule 处理不迁移和映射到已有分类的规则，返回新版分类 ID 和是否已处理
+// 改名、合并和只指定上级分类的规则返回 false，按正常流程创建或更新
+func (m *Migrator) applyCategoryRule(cat models.Category, rule categoryRule, existing *existingIndex, usedSlugs map[string]bool) (int, bool) {
+	switch {
+	case rule.Drop:
+		id, err := m.defaultCategory(existing, usedSlugs)
+		if err != nil {
+			log.Printf("  ✗ %s 不迁移，%v", cat.Name, err)
+			m.stats.Categories.Failed++
+			return 0, true
+		}
+		if id > 0 {
+			log.Printf("  ⊘ %s 不迁移，商品归入默认分类 (ID:%d)", cat.Name, id)
+		} else {
+			log.Printf("  ⊘ %s 不迁移", cat.Name)
+		}
+		m.stats.Categories.Dropped++
+		return id, true
+
+	case rule.Slug != "" || rule.ID > 0:
+		id, ok := resolveExisting(existing, rule)
+		if !ok {
+			log.Printf("  ✗ %s 映射的新版分类不存在 (slug: %q, ID: %d)", cat.Name, rule.Slug, rule.ID)
+			m.stats.Categories.Failed++
+			return 0, true
+		}
+		log.Printf("  ⇢ %s 映射到新版分类 (ID:%d)", cat.Name, id)
+		m.stats.Categories.Mapped++
+		return id, true
+	}
+	return 0, false
+}
+
+// splitCategoryPath 拆分分类路径，返回上级分类名称和末级名称
+// 名称按 category.delimiter 拆分（未设置时不拆分），parent 按分隔符或 / 拆分后放在最前面
+func (m *Migrator) splitCategoryPath(name, parent string) ([]string, string) {
+	sep := m.cfg.Category.Delimiter
+
+	var parents []string
+	if parent != "" {
+		parentSep := sep
+		if parentSep == "" {
+			parentSep = "/"
+		}
+		parents = splitPath(parent, parentSep)
+	}
+	if sep != "" {
+		if parts := splitPath(name, sep); len(parts) > 0 {
+			parents = append(parents, parts[:len(parts)-1]...)
+			name = parts[len(parts)-1]
+		}
+	}
+	return parents, name
+}
+
+// splitPath 按分隔符拆分，去掉空白和空的部分
+func splitPath(s, sep string) []string {
+	var parts []string
+	for _, part := range strings.Split(s, sep) {
+		if part = strings.TrimSpace(part); part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return parts
+}
+
+// ensureCategoryPath 依次查找或创建各级分类，返回最后一级的新版分类 ID
+// 第一级放在站点的 parent_category 下；同一路径在本次运行中只创建一次
+func (m *Migrator) ensureCategoryPath(existing *existingIndex, usedSlugs map[string]bool, names []string) (int, error) {
+	parentID := m.parentID
+	for i, name := range names {
+		path := strings.Join(names[:i+1], "/")
+		if id, ok := m.categoryIDs[path]; ok {
+			parentID = id
+			continue
+		}
+		id, err := m.ensureCategory(existing, usedSlugs, m.conf.CategoryPrefix+name, m.mappedExternalID(path), parentID)
+		if err != nil {
+			return 0, fmt.Errorf("%s: %w", path, err)
+		}
+		m.categoryIDs[path] = id
+		parentID = id
+	}
+	return parentID, nil
+}
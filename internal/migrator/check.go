@@ -0,0 +1,109 @@
+package migrator
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+
+	"github.com/luoyanglang/dujiao-migrate/internal/api"
+	"github.com/luoyanglang/dujiao-migrate/internal/config"
+	"github.com/luoyanglang/dujiao-migrate/internal/database"
+	"github.com/luoyanglang/dujiao-migrate/internal/script"
+)
+
+// Check 检查配置：测试数据库连接、数据表结构和 API 登录，不迁移任何数据
+func Check(cfg *config.Config) error {
+	failed := 0
+
+	for _, conf := range cfg.SourceList() {
+		if conf.Name != "" {
+			log.Printf("站点 %s:", conf.Name)
+		}
+		failed += checkSource(conf)
+	}
+
+	if cfg.Fields.Script != "" {
+		if _, err := script.Load(cfg.Fields.Script); err != nil {
+			log.Printf("✗ %v", err)
+			failed++
+		} else {
+			log.Printf("✓ 转换脚本加载成功 (%s)", cfg.Fields.Script)
+		}
+	}
+
+	client := api.NewClient(cfg.NewAPI.BaseURL, cfg.Options.RetryTimes, cfg.Options.RetryDelay)
+	if err := client.Login(cfg.NewAPI.Username, cfg.NewAPI.Password); err != nil {
+		log.Printf("✗ 新版后台登录失败: %v", err)
+		failed++
+	} else {
+		log.Printf("✓ 新版后台登录成功 (%s)", cfg.NewAPI.BaseURL)
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d 项检查未通过", failed)
+	}
+	return nil
+}
+
+// checkSource 检查一个老版站点的数据库连接和数据表结构，返回未通过的项数
+func checkSource(conf config.SourceConfig) int {
+	failed := 0
+	db := conf.OldDB
+
+	var tunnel *ssh.Client
+	if db.SSH.Enabled() {
+		var err error
+		tunnel, err = database.DialSSH(db.SSH)
+		if err != nil {
+			log.Printf("✗ SSH 隧道连接失败: %v", err)
+			return 1
+		}
+		defer tunnel.Close()
+		log.Printf("✓ SSH 隧道连接成功 (%s)", db.SSH.Host)
+
+		if db.SSH.SFTP && conf.OldSitePath != "" {
+			if client, err := sftp.NewClient(tunnel); err != nil {
+				log.Printf("✗ 建立 SFTP 会话失败: %v", err)
+				failed++
+			} else {
+				if _, err := client.Stat(conf.OldSitePath); err != nil {
+					log.Printf("✗ 远程站点路径 %s 无法访问: %v", conf.OldSitePath, err)
+					failed++
+				} else {
+					log.Printf("✓ SFTP 可访问远程站点路径 %s", conf.OldSitePath)
+				}
+				client.Close()
+			}
+		}
+	}
+
+	conn, err := database.Connect(db, tunnel)
+	if err != nil {
+		log.Printf("✗ 老版数据库连接失败: %v", err)
+		failed++
+	} else {
+		defer conn.Close()
+		log.Printf("✓ 老版数据库连接成功 (%s)", db.Driver)
+
+		schema, err := database.DetectSchema(conn, db.Driver, db.TablePrefix)
+		if err != nil {
+			log.Printf("✗ %v", err)
+			failed++
+		} else {
+			log.Printf("✓ 检测到数据库结构: %s (表前缀: %q)", schema.Variant, schema.Prefix)
+			for _, table := range []string{"goods_group", "goods", "carmis"} {
+				var count int
+				query := fmt.Sprintf("SELECT COUNT(*) FROM %s", schema.Table(table))
+				if err := conn.QueryRow(query).Scan(&count); err != nil {
+					log.Printf("✗ 统计 %s 失败: %v", schema.Table(table), err)
+					failed++
+					continue
+				}
+				log.Printf("  %s: %d 条记录", schema.Table(table), count)
+			}
+		}
+	}
+	return failed
+}
@@ -0,0 +1,222 @@
+package migrator
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/luoyanglang/dujiao-migrate/internal/config"
+)
+
+// 已存在数据的匹配方式
+const (
+	matchExternalID = "external_id" // 按创建时写入的老版 ID 标记
+	matchSlug       = "slug"        // 按 slug
+	matchTitle      = "title"       // 按规范化后的名称
+)
+
+// externalID 老版数据标记，如 dujiaoka:goods:12，创建时写入 external_id 字段
+func externalID(table string, id int) string {
+	return fmt.Sprintf("dujiaoka:%s:%d", table, id)
+}
+
+// existingItem 新版已存在的分类或商品
+type existingItem struct {
+	ID         int
+	Slug       string
+	ExternalID string
+	Data       map[string]interface{} // 列表接口返回的原始数据
+}
+
+// existingIndex 新版已存在数据的索引
+type existingIndex struct {
+	byID         map[int]existingItem
+	byExternalID map[string]existingItem
+	bySlug       map[string]existingItem
+	byTitle      map[string]existingItem // 规范化名称，同名多条时只保留第一条
+}
+
+func newExistingIndex() *existingIndex {
+	return &existingIndex{
+		byID:         make(map[int]existingItem),
+		byExternalID: make(map[string]existingItem),
+		bySlug:       make(map[string]existingItem),
+		byTitle:      make(map[string]existingItem),
+	}
+}
+
+// add 加入一条新版数据，titleField 为名称字段（分类为 name，商品为 title）
+func (idx *existingIndex) add(data map[string]interface{}, titleField string) {
+	id, ok := data["id"].(float64)
+	if !ok {
+		return
+	}
+	item := existingItem{ID: int(id), Data: data}
+	item.Slug, _ = data["slug"].(string)
+	item.ExternalID, _ = data["external_id"].(string)
+
+	idx.byID[item.ID] = item
+	if item.ExternalID != "" {
+		idx.byExternalID[item.ExternalID] = item
+	}
+	if item.Slug != "" {
+		idx.bySlug[item.Slug] = item
+	}
+	for _, title := range titles(data[titleField]) {
+		if key := normalizeTitle(title); key != "" {
+			if _, exists := idx.byTitle[key]; !exists {
+				idx.byTitle[key] = item
+			}
+		}
+	}
+}
+
+// slugs 已占用的 slug
+func (idx *existingIndex) slugs() map[string]bool {
+	used := make(map[string]bool, len(idx.bySlug))
+	for slug := range idx.bySlug {
+		used[slug] = true
+	}
+	return used
+}
+
+// match 按配置的顺序查找老版数据对应的新版数据，返回新版数据和命中的匹配方式
+// 按 slug 或名称命中、但新版数据带有其他老版 ID 标记时，视为不同数据
+func (idx *existingIndex) match(modes []string, extID, slug, title string) (existingItem, string, bool) {
+	for _, mode := range modes {
+		var item existingItem
+		var ok bool
+		switch mode {
+		case matchExternalID:
+			item, ok = idx.byExternalID[extID]
+		case matchSlug:
+			item, ok = idx.bySlug[slug]
+		case matchTitle:
+			if key := normalizeTitle(title); key != "" {
+				item, ok = idx.byTitle[key]
+			}
+		}
+		if !ok {
+			continue
+		}
+		if item.ExternalID != "" && item.ExternalID != extID {
+			continue
+		}
+		return item, mode, true
+	}
+	return existingItem{}, "", false
+}
+
+// probeExternalID 启动时读取一条新版已有的分类或商品，检查接口是否返回 external_id；
+// 新版还没有数据时在创建第一条数据后检查
+func (m *Migrator) probeExternalID() {
+	for _, endpoint := range []string{"/categories", "/products"} {
+		resp, err := m.client.Get(endpoint + "?page=1&page_size=1")
+		if err != nil || resp.StatusCode != 0 {
+			continue
+		}
+		for _, entry := range extractDataList(resp.Data) {
+			if item, ok := entry.(map[string]interface{}); ok {
+				m.checkExternalID(item)
+				return
+			}
+		}
+	}
+}
+
+// checkExternalID 检查新版数据是否带有 external_id 字段，不带时无法识别已迁移的数据
+// match_by 为默认值时不再按 slug 匹配，只按 state_file 中的 ID 映射识别，避免新版同 slug 的其他数据被当作已存在
+func (m *Migrator) checkExternalID(item map[string]interface{}) {
+	if m.externalIDKnown {
+		return
+	}
+	m.externalIDKnown = true
+	if _, ok := item["external_id"]; ok {
+		return
+	}
+	if slices.Equal(m.matchBy, config.DefaultConfig().Options.MatchBy) {
+		m.matchBy = []string{matchExternalID}
+		log.Println("⚠ 新版接口没有返回 external_id，无法按老版 ID 判断已迁移的数据；只按 state_file 中的 ID 映射识别，需要按 slug 或名称匹配时请设置 match_by")
+		return
+	}
+	log.Println("⚠ 新版接口没有返回 external_id，无法按老版 ID 判断已迁移的数据；按 slug 或名称匹配时新版同 slug 或同名的其他数据也会被当作已存在")
+}
+
+// fetchDetail 获取新版资源详情，接口不支持时返回 nil
+func (m *Migrator) fetchDetail(endpoint string, id int) map[string]interface{} {
+	resp, err := m.client.Get(fmt.Sprintf("%s/%d", endpoint, id))
+	if err != nil || resp.StatusCode != 0 {
+		return nil
+	}
+	data, _ := resp.Data.(map[string]interface{})
+	return data
+}
+
+// titles 取出名称的所有语言版本，兼容字符串和 {"zh-CN": ...} 两种格式
+func titles(v interface{}) []string {
+	switch t := v.(type) {
+	case string:
+		return []string{t}
+	case map[string]interface{}:
+		var out []string
+		for _, s := range t {
+			if str, ok := s.(string); ok {
+				out = append(out, str)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+// normalizeTitle 规范化名称：转小写，只保留字母和数字
+func normalizeTitle(s string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(s) {
+		if unicode.IsLetter(r) || unicode.IsNumber(r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// getExistingItems 获取新版已存在的分类或商品
+func (m *Migrator) getExistingItems(endpoint, titleField string) (*existingIndex, error) {
+	idx := newExistingIndex()
+	page := 1
+	maxPages := 100
+
+	for page <= maxPages {
+		resp, err := m.client.Get(fmt.Sprintf("%s?page=%d&page_size=100", endpoint, page))
+		if err != nil {
+			return idx, err
+		}
+
+		if resp.StatusCode != 0 {
+			break
+		}
+
+		// API 返回格式可能是 {data: [...]} 或 {data: {data: [...]}}
+		dataList := extractDataList(resp.Data)
+		if len(dataList) == 0 {
+			break
+		}
+
+		beforeCount := len(idx.byID)
+		for _, item := range dataList {
+			if itemMap, ok := item.(map[string]interface{}); ok {
+				idx.add(itemMap, titleField)
+			}
+		}
+
+		if len(idx.byID) == beforeCount {
+			break
+		}
+
+		page++
+	}
+
+	return idx, nil
+}
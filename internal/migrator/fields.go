@@ -0,0 +1,307 @@
+package migrator
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/shopspring/decimal"
+
+	"github.com/luoyanglang/dujiao-migrate/internal/config"
+	"github.com/luoyanglang/dujiao-migrate/internal/models"
+)
+
+// fieldRule 编译后的字段规则
+type fieldRule struct {
+	field string
+	rule  config.FieldRule
+	tmpl  *template.Template
+}
+
+// compileFieldRules 编译字段规则，按字段名排序保证执行顺序稳定
+func compileFieldRules(kind string, rules map[string]config.FieldRule) ([]fieldRule, error) {
+	compiled := make([]fieldRule, 0, len(rules))
+	for field, rule := range rules {
+		fr := fieldRule{field: field, rule: rule}
+		if rule.Template != "" {
+			tmpl, err := template.New(field).Funcs(templateFuncs).Option("missingkey=error").Parse(rule.Template)
+			if err != nil {
+				return nil, fmt.Errorf("fields.%s.%s 模板错误: %w", kind, field, err)
+			}
+			fr.tmpl = tmpl
+		}
+		compiled = append(compiled, fr)
+	}
+	sort.Slice(compiled, func(i, j int) bool { return compiled[i].field < compiled[j].field })
+	return compiled, nil
+}
+
+// needsRow 是否需要读取老版数据的原始行
+func needsRow(rules []fieldRule) bool {
+	for _, fr := range rules {
+		if fr.rule.Column != "" || fr.tmpl != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// applyFieldRules 按规则覆盖请求字段
+//
+// data 为模板数据：老版原始列（如 .actual_price）、模型字段（如 .ActualPrice）和 .Payload（当前请求）
+// 结果类型未指定时与原字段相同，原字段是多语言字段时由字符串生成多语言内容
+func (m *Migrator) applyFieldRules(rules []fieldRule, payload, data map[string]interface{}) error {
+	for _, fr := range rules {
+		var value interface{}
+		switch {
+		case fr.tmpl != nil:
+			var b strings.Builder
+			if err := fr.tmpl.Execute(&b, data); err != nil {
+				return fmt.Errorf("字段 %s: %w", fr.field, err)
+			}
+			value = b.String()
+		case fr.rule.Column != "":
+			v, ok := data[fr.rule.Column]
+			if !ok {
+				return fmt.Errorf("字段 %s: 老版数据表没有 %s 列", fr.field, fr.rule.Column)
+			}
+			value = v
+		default:
+			value = fr.rule.Value
+		}
+
+		converted, err := m.convertField(value, fr.rule.Type, payload[fr.field])
+		if err != nil {
+			return fmt.Errorf("字段 %s: %w", fr.field, err)
+		}
+		payload[fr.field] = converted
+	}
+	return nil
+}
+
+// convertField 把规则结果转换为指定类型，未指定时按原字段的类型转换
+func (m *Migrator) convertField(value interface{}, typ string, current interface{}) (interface{}, error) {
+	if typ == "" {
+		switch current.(type) {
+		case int, int64:
+			typ = "int"
+		case float64:
+			typ = "float"
+		case priceAmount:
+			typ = "decimal"
+		case bool:
+			typ = "bool"
+		case map[string]string:
+			typ = "localized"
+		case []string, []interface{}, map[string]interface{}:
+			typ = "json"
+		default:
+			return value, nil
+		}
+	}
+
+	s := strings.TrimSpace(fmt.Sprint(value))
+	switch typ {
+	case "string":
+		return fmt.Sprint(value), nil
+	case "int":
+		f, err := toFloat(value)
+		if err != nil {
+			return nil, err
+		}
+		return int(math.Round(f)), nil
+	case "float":
+		return toFloat(value)
+	case "decimal":
+		d, err := toDecimal(value)
+		if err != nil {
+			return nil, err
+		}
+		return priceAmount{d}, nil
+	case "bool":
+		if b, ok := value.(bool); ok {
+			return b, nil
+		}
+		return strconv.ParseBool(s)
+	case "localized":
+		if _, ok := value.(string); !ok {
+			return value, nil
+		}
+		return m.localize(value.(string)), nil
+	case "json":
+		str, ok := value.(string)
+		if !ok {
+			return value, nil
+		}
+		var v interface{}
+		if err := json.Unmarshal([]byte(str), &v); err != nil {
+			return nil, fmt.Errorf("不是有效的 JSON: %q", str)
+		}
+		return v, nil
+	}
+	return value, nil
+}
+
+// toFloat 转换为浮点数，支持数字和数字字符串
+func toFloat(v interface{}) (float64, error) {
+	switch n := v.(type) {
+	case float64:
+		return n, nil
+	case float32:
+		return float64(n), nil
+	case int:
+		return float64(n), nil
+	case int64:
+		return float64(n), nil
+	case nil:
+		return 0, nil
+	}
+	s := strings.TrimSpace(fmt.Sprint(v))
+	if s == "" {
+		return 0, nil
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, fmt.Errorf("不是有效的数字: %q", s)
+	}
+	return f, nil
+}
+
+// templateFuncs 模板函数，四则运算和取整按十进制计算（0.1 + 0.2 = 0.3）
+var templateFuncs = template.FuncMap{
+	"add": func(a, b interface{}) (decimal.Decimal, error) {
+		return arith(a, b, decimal.Decimal.Add)
+	},
+	"sub": func(a, b interface{}) (decimal.Decimal, error) {
+		return arith(a, b, decimal.Decimal.Sub)
+	},
+	"mul": func(a, b interface{}) (decimal.Decimal, error) {
+		return arith(a, b, decimal.Decimal.Mul)
+	},
+	"div": func(a, b interface{}) (decimal.Decimal, error) {
+		y, err := toDecimal(b)
+		if err != nil {
+			return decimal.Zero, err
+		}
+		if y.IsZero() {
+			return decimal.Zero, fmt.Errorf("除数为 0")
+		}
+		x, err := toDecimal(a)
+		return x.Div(y), err
+	},
+	"round": func(v interface{}, places int) (decimal.Decimal, error) {
+		d, err := toDecimal(v)
+		return d.Round(int32(places)), err
+	},
+	"default": func(def, v interface{}) interface{} {
+		if v == nil || strings.TrimSpace(fmt.Sprint(v)) == "" {
+			return def
+		}
+		return v
+	},
+	"trim":     func(v interface{}) string { return strings.TrimSpace(fmt.Sprint(v)) },
+	"lower":    func(v interface{}) string { return strings.ToLower(fmt.Sprint(v)) },
+	"upper":    func(v interface{}) string { return strings.ToUpper(fmt.Sprint(v)) },
+	"replace":  func(old, new string, v interface{}) string { return strings.ReplaceAll(fmt.Sprint(v), old, new) },
+	"contains": func(sub string, v interface{}) bool { return strings.Contains(fmt.Sprint(v), sub) },
+	"split": func(sep string, v interface{}) []string {
+		var parts []string
+		for _, part := range strings.Split(fmt.Sprint(v), sep) {
+			if part = strings.TrimSpace(part); part != "" {
+				parts = append(parts, part)
+			}
+		}
+		return parts
+	},
+	"json": func(v interface{}) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+}
+
+func arith(a, b interface{}, op func(x, y decimal.Decimal) decimal.Decimal) (decimal.Decimal, error) {
+	x, err := toDecimal(a)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	y, err := toDecimal(b)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	return op(x, y), nil
+}
+
+// rowData 模板数据：原始列、模型字段（sql.NullString 转为字符串，时间转为 "2006-01-02 15:04:05"）和当前请求
+func rowData(raw map[string]interface{}, model interface{}, payload map[string]interface{}) map[string]interface{} {
+	data := make(map[string]interface{}, len(raw)+16)
+	for k, v := range raw {
+		data[k] = v
+	}
+
+	v := reflect.ValueOf(model)
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		fv := v.Field(i).Interface()
+		switch nv := fv.(type) {
+		case sql.NullString:
+			fv = nv.String
+		case models.NullTime:
+			fv = ""
+			if nv.Valid {
+				fv = nv.Time.Format("2006-01-02 15:04:05")
+			}
+		}
+		data[t.Field(i).Name] = fv
+	}
+	data["Payload"] = payload
+	return data
+}
+
+// rawRows 按条件读取老版数据的原始行（SELECT *），按 ID 索引
+func (m *Migrator) rawRows(table, where string, args []interface{}) (map[int]map[string]interface{}, error) {
+	s := m.schema
+	rows, err := m.db.Query(s.Rebind(fmt.Sprintf("SELECT * FROM %s WHERE %s", s.Table(table), where)), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	cols, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	idCol := s.Col(table, "id")
+
+	result := make(map[int]map[string]interface{})
+	for rows.Next() {
+		values := make([]interface{}, len(cols))
+		ptrs := make([]interface{}, len(cols))
+		for i := range values {
+			ptrs[i] = &values[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			return nil, err
+		}
+
+		row := make(map[string]interface{}, len(cols))
+		for i, col := range cols {
+			if b, ok := values[i].([]byte); ok {
+				row[col] = string(b)
+			} else {
+				row[col] = values[i]
+			}
+		}
+		id, err := toFloat(row[idCol])
+		if err != nil {
+			continue
+		}
+		result[int(id)] = row
+	}
+	return result, rows.Err()
+}
@@ -0,0 +1,44 @@
+package migrator
+
+import (
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/pkg/sftp"
+)
+
+// siteFiles 老版站点文件访问（本地或 SFTP）
+type siteFiles interface {
+	Join(elem ...string) string
+	IsAbs(name string) bool
+	Stat(name string) (os.FileInfo, error)
+	ReadFile(name string) ([]byte, error)
+}
+
+// localFiles 读取本机文件
+type localFiles struct{}
+
+func (localFiles) Join(elem ...string) string            { return filepath.Join(elem...) }
+func (localFiles) IsAbs(name string) bool                { return filepath.IsAbs(name) }
+func (localFiles) Stat(name string) (os.FileInfo, error) { return os.Stat(name) }
+func (localFiles) ReadFile(name string) ([]byte, error)  { return os.ReadFile(name) }
+
+// sftpFiles 通过 SFTP 读取老版服务器上的文件
+type sftpFiles struct {
+	client *sftp.Client
+}
+
+func (f sftpFiles) Join(elem ...string) string            { return path.Join(elem...) }
+func (f sftpFiles) IsAbs(name string) bool                { return path.IsAbs(name) }
+func (f sftpFiles) Stat(name string) (os.FileInfo, error) { return f.client.Stat(name) }
+
+func (f sftpFiles) ReadFile(name string) ([]byte, error) {
+	file, err := f.client.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return io.ReadAll(file)
+}
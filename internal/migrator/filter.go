@@ -0,0 +1,200 @@
+package migrator
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+
+	"github.com/luoyanglang/dujiao-migrate/internal/config"
+	"github.com/luoyanglang/dujiao-migrate/internal/utils"
+)
+
+// selection 选择性迁移的过滤条件
+//
+// 分类 ID、商品 ID、SQL 条件和创建时间直接拼入查询；名称正则在程序中判断。
+// 设置了商品级条件时，分类只迁移包含选中商品的分类，卡密只迁移选中商品的卡密
+type selection struct {
+	categories   utils.IDList
+	products     utils.IDList
+	where        string
+	name         *regexp.Regexp
+	createdAfter string
+
+	productIDs map[int]bool // 选中的商品，未设置过滤条件时为 nil
+	groupIDs   map[int]bool // 选中商品所在的分类，没有商品级条件时为 nil
+}
+
+// newSelection 解析过滤配置，配置已经过校验
+func newSelection(cfg config.FilterConfig) (*selection, error) {
+	sel := &selection{where: strings.TrimSpace(cfg.Where)}
+
+	var err error
+	if sel.categories, err = utils.ParseIDList(cfg.Categories); err != nil {
+		return nil, fmt.Errorf("filter.categories: %w", err)
+	}
+	if sel.products, err = utils.ParseIDList(cfg.Products); err != nil {
+		return nil, fmt.Errorf("filter.products: %w", err)
+	}
+	if cfg.NamePattern != "" {
+		if sel.name, err = regexp.Compile(cfg.NamePattern); err != nil {
+			return nil, fmt.Errorf("filter.name_pattern: %w", err)
+		}
+	}
+	if cfg.CreatedAfter != "" {
+		t, err := utils.ParseDate(cfg.CreatedAfter)
+		if err != nil {
+			return nil, fmt.Errorf("filter.created_after: %w", err)
+		}
+		sel.createdAfter = t.Format("2006-01-02 15:04:05")
+	}
+	return sel, nil
+}
+
+// active 是否设置了任一过滤条件
+func (sel *selection) active() bool {
+	return len(sel.categories) > 0 || sel.productLevel()
+}
+
+// productLevel 是否设置了商品级过滤条件
+func (sel *selection) productLevel() bool {
+	return len(sel.products) > 0 || sel.where != "" || sel.name != nil || sel.createdAfter != ""
+}
+
+// describe 过滤条件说明，用于日志
+func (sel *selection) describe() string {
+	var parts []string
+	if len(sel.categories) > 0 {
+		parts = append(parts, "分类 "+sel.categories.String())
+	}
+	if len(sel.products) > 0 {
+		parts = append(parts, "商品 "+sel.products.String())
+	}
+	if sel.where != "" {
+		parts = append(parts, "条件 "+sel.where)
+	}
+	if sel.name != nil {
+		parts = append(parts, "名称匹配 "+sel.name.String())
+	}
+	if sel.createdAfter != "" {
+		parts = append(parts, "创建于 "+sel.createdAfter+" 之后")
+	}
+	return strings.Join(parts, "，")
+}
+
+// category 分类是否被选中
+func (sel *selection) category(id int) bool {
+	if len(sel.categories) > 0 && !sel.categories.Contains(id) {
+		return false
+	}
+	return sel.groupIDs == nil || sel.groupIDs[id]
+}
+
+// product 商品是否被选中
+func (sel *selection) product(id int) bool {
+	return sel.productIDs == nil || sel.productIDs[id]
+}
+
+// filterConditions 返回数据表的过滤 SQL 条件，没有条件时返回空字符串
+func (m *Migrator) filterConditions(table string) (string, []interface{}) {
+	sel := m.filter
+	s := m.schema
+	var conds []string
+	var args []interface{}
+
+	switch table {
+	case "goods_group":
+		if cond, a := idListCondition(s.Col(table, "id"), sel.categories); cond != "" {
+			conds = append(conds, cond)
+			args = append(args, a...)
+		}
+	case "goods":
+		if cond, a := idListCondition(s.Col(table, "group_id"), sel.categories); cond != "" {
+			conds = append(conds, cond)
+			args = append(args, a...)
+		}
+		if cond, a := idListCondition(s.Col(table, "id"), sel.products); cond != "" {
+			conds = append(conds, cond)
+			args = append(args, a...)
+		}
+		if sel.where != "" {
+			conds = append(conds, "("+sel.where+")")
+		}
+		if sel.createdAfter != "" {
+			conds = append(conds, s.Col(table, "created_at")+" >= ?")
+			args = append(args, sel.createdAfter)
+		}
+	}
+	return strings.Join(conds, " AND "), args
+}
+
+// idListCondition 生成 ID 列表的 SQL 条件
+func idListCondition(col string, list utils.IDList) (string, []interface{}) {
+	if len(list) == 0 {
+		return "", nil
+	}
+	conds := make([]string, 0, len(list))
+	args := make([]interface{}, 0, len(list)*2)
+	for _, r := range list {
+		if r.From == r.To {
+			conds = append(conds, col+" = ?")
+			args = append(args, r.From)
+		} else {
+			conds = append(conds, col+" BETWEEN ? AND ?")
+			args = append(args, r.From, r.To)
+		}
+	}
+	return "(" + strings.Join(conds, " OR ") + ")", args
+}
+
+// prepareFilter 查询符合过滤条件的商品，确定要迁移的分类、商品和卡密
+// 包含已删除和已停用的商品，增量同步时用于下架
+func (m *Migrator) prepareFilter() error {
+	sel := m.filter
+	sel.productIDs, sel.groupIDs = nil, nil
+	if !sel.active() {
+		return nil
+	}
+
+	s := m.schema
+	if sel.createdAfter != "" && !s.Has("goods", "created_at") {
+		return fmt.Errorf("filter.created_after 需要商品表有 created_at 字段，当前数据库没有，请改用 filter.where 或去掉该条件")
+	}
+	where, args := m.filterConditions("goods")
+	if where == "" {
+		where = "1 = 1"
+	}
+	query := s.Rebind(fmt.Sprintf("SELECT %s FROM %s WHERE %s",
+		s.Cols("goods", "id", "group_id", "name"), s.Table("goods"), where))
+	rows, err := m.db.Query(query, args...)
+	if err != nil {
+		return fmt.Errorf("按过滤条件查询商品失败: %w", err)
+	}
+	defer rows.Close()
+
+	sel.productIDs = make(map[int]bool)
+	groupIDs := make(map[int]bool)
+	for rows.Next() {
+		var id, groupID int
+		var name string
+		if err := rows.Scan(&id, &groupID, &name); err != nil {
+			return err
+		}
+		if sel.name != nil && !sel.name.MatchString(name) {
+			continue
+		}
+		sel.productIDs[id] = true
+		groupIDs[groupID] = true
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if sel.productLevel() {
+		sel.groupIDs = groupIDs
+	}
+	if !m.syncing {
+		log.Printf("过滤条件: %s（选中 %d 个商品）", sel.describe(), len(sel.productIDs))
+	}
+	return nil
+}
@@ -0,0 +1,226 @@
+package migrator
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// formFieldTypes 手动发货表单支持的字段类型
+var formFieldTypes = []string{"text", "textarea", "select", "radio", "number", "email"}
+
+// formKeyPattern 表单字段 key
+var formKeyPattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// formField 老版 other_ipu_cnf 中的一个表单字段
+type formField struct {
+	Key         string
+	Label       string
+	Type        string
+	Required    bool
+	Placeholder string
+	Default     string
+	Options     []string
+	Pattern     string
+	Min, Max    string
+}
+
+// parseFormConfig 解析老版 other_ipu_cnf，返回表单字段和无法解析的行的说明
+//
+// 每行一个字段，用 | 分隔（没有 | 时用 =，即独角数卡原生的 key=label=required 写法）：
+//
+//	account|充值账号|1
+//	remark|备注|0|1                               # 第 4 列为 1 表示多行文本（旧写法）
+//	server|区服|1|select|options:一区,二区,三区
+//	qty|数量|1|number|min:1|max:100|default:1
+//	email|邮箱|1|email|placeholder:用于接收卡密
+//	qq|QQ|1|text|pattern:^[1-9][0-9]{4,}$
+//
+// 第 4 列起为字段类型或 名称:值 形式的属性，pattern 之后的内容（包括 |）整体作为正则，须写在最后；
+// 无法解析的行跳过并返回说明，其余字段照常迁移
+func parseFormConfig(cnf string) ([]formField, []string) {
+	var fields []formField
+	var warnings []string
+	keys := make(map[string]bool)
+
+	for i, line := range strings.Split(strings.ReplaceAll(cnf, "\r\n", "\n"), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		warn := func(format string, args ...interface{}) {
+			warnings = append(warnings, fmt.Sprintf("第 %d 行 %q: %s", i+1, line, fmt.Sprintf(format, args...)))
+		}
+
+		sep := "|"
+		if !strings.Contains(line, sep) {
+			sep = "="
+		}
+		parts := strings.Split(line, sep)
+		// 正则中可能包含分隔符，pattern 之后的内容整体作为正则
+		for j := 3; j < len(parts); j++ {
+			if strings.HasPrefix(strings.ToLower(strings.TrimSpace(parts[j])), "pattern:") {
+				parts = append(parts[:j], strings.Join(parts[j:], sep))
+				break
+			}
+		}
+		for j := range parts {
+			parts[j] = strings.TrimSpace(parts[j])
+		}
+		if len(parts) < 2 || parts[1] == "" {
+			warn("至少需要 key 和名称两列")
+			continue
+		}
+
+		field := formField{Key: parts[0], Label: parts[1], Type: "text"}
+		if !formKeyPattern.MatchString(field.Key) {
+			warn("key 只能包含字母、数字、- 和 _")
+			continue
+		}
+		if keys[field.Key] {
+			warn("key %s 重复", field.Key)
+			continue
+		}
+
+		if len(parts) > 2 {
+			required, ok := parseFlag(parts[2])
+			if !ok {
+				warn("第 3 列 %q 应为 1/0 或 true/false", parts[2])
+				continue
+			}
+			field.Required = required
+		}
+
+		if err := field.parseAttrs(parts[3:]); err != nil {
+			warn("%v", err)
+			continue
+		}
+
+		keys[field.Key] = true
+		fields = append(fields, field)
+	}
+	return fields, warnings
+}
+
+// parseAttrs 解析第 4 列起的字段类型和属性
+func (f *formField) parseAttrs(attrs []string) error {
+	for i, attr := range attrs {
+		if attr == "" {
+			continue
+		}
+		name, value, hasValue := strings.Cut(attr, ":")
+		if !hasValue {
+			switch {
+			case i == 0 && attr == "1":
+				f.Type = "textarea"
+			case i == 0 && attr == "0":
+			case contains(formFieldTypes, strings.ToLower(attr)):
+				f.Type = strings.ToLower(attr)
+			default:
+				return fmt.Errorf("不支持的字段类型 %q，可选值: %s", attr, strings.Join(formFieldTypes, ", "))
+			}
+			continue
+		}
+
+		value = strings.TrimSpace(value)
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "options":
+			f.Options = splitOptions(value)
+		case "placeholder":
+			f.Placeholder = value
+		case "default":
+			f.Default = value
+		case "pattern":
+			if _, err := regexp.Compile(value); err != nil {
+				return fmt.Errorf("pattern 不是有效的正则表达式: %v", err)
+			}
+			f.Pattern = value
+		case "min", "max":
+			if _, err := strconv.ParseFloat(value, 64); err != nil {
+				return fmt.Errorf("%s 应为数字", name)
+			}
+			if strings.EqualFold(name, "min") {
+				f.Min = value
+			} else {
+				f.Max = value
+			}
+		default:
+			return fmt.Errorf("不支持的属性 %q，可选: options, placeholder, default, pattern, min, max", name)
+		}
+	}
+
+	if (f.Type == "select" || f.Type == "radio") && len(f.Options) == 0 {
+		return fmt.Errorf("%s 类型需要 options", f.Type)
+	}
+	if len(f.Options) > 0 && f.Type != "select" && f.Type != "radio" {
+		return fmt.Errorf("options 只能用于 select 和 radio 类型")
+	}
+	if f.Default != "" && len(f.Options) > 0 && !contains(f.Options, f.Default) {
+		return fmt.Errorf("default %q 不在 options 中", f.Default)
+	}
+	return nil
+}
+
+// splitOptions 拆分选项，支持英文逗号和中文逗号（选项本身可能包含 /，如 "月卡/30天"）
+func splitOptions(s string) []string {
+	var options []string
+	for _, opt := range strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == '，' }) {
+		if opt = strings.TrimSpace(opt); opt != "" {
+			options = append(options, opt)
+		}
+	}
+	return options
+}
+
+// parseFlag 解析 1/0、true/false、yes/no，空值为 false
+func parseFlag(s string) (bool, bool) {
+	switch strings.ToLower(s) {
+	case "1", "true", "yes", "y", "是":
+		return true, true
+	case "", "0", "false", "no", "n", "否":
+		return false, true
+	}
+	return false, false
+}
+
+// formSchema 生成新版手动发货表单，文本按多语言生成
+func (m *Migrator) formSchema(fields []formField) map[string]interface{} {
+	items := make([]interface{}, 0, len(fields))
+	for _, f := range fields {
+		item := map[string]interface{}{
+			"key":      f.Key,
+			"type":     f.Type,
+			"required": f.Required,
+			"label":    m.localize(f.Label),
+		}
+		if f.Placeholder != "" {
+			item["placeholder"] = m.localize(f.Placeholder)
+		}
+		if f.Default != "" {
+			item["default"] = f.Default
+		}
+		if len(f.Options) > 0 {
+			options := make([]interface{}, len(f.Options))
+			for i, opt := range f.Options {
+				options[i] = map[string]interface{}{"value": opt, "label": m.localize(opt)}
+			}
+			item["options"] = options
+		}
+		validation := map[string]interface{}{}
+		if f.Pattern != "" {
+			validation["pattern"] = f.Pattern
+		}
+		if f.Min != "" {
+			validation["min"], _ = strconv.ParseFloat(f.Min, 64)
+		}
+		if f.Max != "" {
+			validation["max"], _ = strconv.ParseFloat(f.Max, 64)
+		}
+		if len(validation) > 0 {
+			item["validation"] = validation
+		}
+		items = append(items, item)
+	}
+	return map[string]interface{}{"fields": items}
+}
@@ -0,0 +1,67 @@
+package migrator
+
+import (
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/luoyanglang/dujiao-migrate/internal/models"
+)
+
+// fulfillmentTypes 独角数卡商品类型与新版发货方式的对应关系，可由 fulfillment.types 覆盖
+var fulfillmentTypes = map[int]string{
+	1: "auto",   // 自动发货（卡密）
+	2: "manual", // 人工处理（代充等，下单时填写 other_ipu_cnf 表单）
+}
+
+// fulfillmentType 返回老版商品类型对应的新版发货方式，skip 表示不迁移
+// 没有对应关系的类型按手动发货迁移，每种类型只提示一次
+func (m *Migrator) fulfillmentType(prod models.Product) string {
+	if t, ok := m.cfg.Fulfillment.Types[strconv.Itoa(prod.Type)]; ok {
+		return t
+	}
+	if t, ok := fulfillmentTypes[prod.Type]; ok {
+		return t
+	}
+	if !m.warnedTypes[prod.Type] {
+		m.warnedTypes[prod.Type] = true
+		log.Printf("  ⚠ 老版商品类型 %d 在新版没有对应的发货方式，按手动发货迁移（可在 fulfillment.types 中指定）", prod.Type)
+	}
+	return "manual"
+}
+
+// applyAPIHook 把老版 api_hook（支付后回调地址）写入新版商品的回调字段
+// fulfillment.api_hook 为 ignore 或已确认新版接口不返回回调字段时不迁移并提示，新版需要手动配置回调
+func (m *Migrator) applyAPIHook(prod models.Product, payload map[string]interface{}) {
+	hook := strings.TrimSpace(nullStr(prod.APIHook))
+	if hook == "" {
+		return
+	}
+	if m.cfg.Fulfillment.APIHook != "webhook" || m.hookMissing {
+		log.Printf("  ⚠ %s 的 api_hook 未迁移（%s），请在新版手动配置回调", prod.Name, hook)
+		m.stats.Products.HookSkipped++
+		return
+	}
+	payload[m.cfg.Fulfillment.WebhookField] = hook
+}
+
+// checkHook 检查新版商品的回调字段是否保存了 api_hook，没有保存时提示并计入未迁移
+//
+// 新版接口不返回该字段时只提示一次，之后的商品按 ignore 处理，更新时也不再比较该字段
+func (m *Migrator) checkHook(name string, id int, payload, detail map[string]interface{}) {
+	field := m.cfg.Fulfillment.WebhookField
+	hook, ok := payload[field].(string)
+	if !ok || m.cfg.Fulfillment.APIHook != "webhook" {
+		return
+	}
+
+	saved, returned := detail[field]
+	if !returned && !m.hookMissing {
+		m.hookMissing = true
+		log.Printf("  ⚠ 新版商品接口没有返回 %s 字段，api_hook 可能无法迁移；确认新版不支持时请设置 fulfillment.api_hook: ignore", field)
+	}
+	if s, _ := saved.(string); s != hook {
+		log.Printf("  ⚠ %s 的 api_hook 未保存到新版 %s 字段 (ID:%d)，请在新版手动配置回调: %s", name, field, id, hook)
+		m.stats.Products.HookSkipped++
+	}
+}
@@ -4,53 +4,145 @@ import (
 	"database/sql"
 	"fmt"
 	"log"
-	"os"
-	"path/filepath"
+	"path"
 	"strings"
 	"time"
 
 	"github.com/luoyanglang/dujiao-migrate/internal/api"
 	"github.com/luoyanglang/dujiao-migrate/internal/config"
-	"github.com/luoyanglang/dujiao-migrate/internal/database"
 	"github.com/luoyanglang/dujiao-migrate/internal/models"
+	"github.com/luoyanglang/dujiao-migrate/internal/script"
+	"github.com/luoyanglang/dujiao-migrate/internal/translate"
 	"github.com/luoyanglang/dujiao-migrate/internal/utils"
+	"github.com/luoyanglang/dujiao-migrate/internal/zhconv"
 )
 
 // Migrator 迁移器
 type Migrator struct {
 	cfg    *config.Config
-	db     *sql.DB
 	client *api.Client
 	stats  models.Stats
+
+	*source           // 正在迁移的老版站点
+	sources []*source // 全部老版站点，按配置顺序迁移
+
+	zhTW       *zhconv.Converter    // 繁体转换器，未启用时为 nil
+	translator translate.Translator // en-US 翻译器
+
+	slugger       *utils.Slugger
+	slugOverrides slugOverrides
+
+	syncing bool       // 增量同步模式
+	filter  *selection // 选择性迁移的过滤条件
+
+	productFields []fieldRule    // 商品字段规则
+	warnedTypes   map[int]bool   // 已提示过没有对应发货方式的老版商品类型
+	hookMissing   bool           // 新版商品接口不返回回调字段，更新时不再比较该字段
+	script        *script.Engine // 转换脚本，未设置时为 nil
+	pricing       *pricing       // 价格换算
+
+	externalIDKnown bool     // 已确认新版接口是否返回 external_id
+	matchBy         []string // 判断已存在的方式，新版不返回 external_id 时默认配置不再按 slug 匹配
 }
 
 // New 创建迁移器
 func New(cfg *config.Config) (*Migrator, error) {
-	db, err := database.Connect(cfg.OldDB)
+	m := &Migrator{cfg: cfg, warnedTypes: make(map[int]bool), matchBy: cfg.Options.MatchBy}
+
+	var sourceNames []string
+	for _, conf := range cfg.SourceList() {
+		sourceNames = append(sourceNames, conf.Name)
+	}
+	overrides, err := loadSlugOverrides(cfg.Slug.OverrideFile, sourceNames)
 	if err != nil {
-		return nil, fmt.Errorf("连接老版数据库失败: %w", err)
+		return nil, err
 	}
-	log.Println("✓ 老版数据库连接成功")
+	m.slugOverrides = overrides
 
-	client := api.NewClient(cfg.NewAPI.BaseURL, cfg.Options.RetryTimes, cfg.Options.RetryDelay)
+	if m.filter, err = newSelection(cfg.Filter); err != nil {
+		return nil, err
+	}
+
+	if m.productFields, err = compileFieldRules("products", cfg.Fields.Products); err != nil {
+		return nil, err
+	}
 
-	if err := client.Login(cfg.NewAPI.Username, cfg.NewAPI.Password); err != nil {
-		db.Close()
+	if m.pricing, err = newPricing(cfg.Price); err != nil {
+		return nil, err
+	}
+	if cfg.Price.Currency != cfg.Price.SourceCurrency {
+		log.Printf("✓ 价格换算: 1 %s = %s %s (取整: %s)", cfg.Price.SourceCurrency, m.pricing.rate, cfg.Price.Currency, cfg.Price.Rounding)
+	}
+
+	if cfg.Fields.Script != "" {
+		if m.script, err = script.Load(cfg.Fields.Script); err != nil {
+			return nil, err
+		}
+		log.Printf("✓ 已加载转换脚本 (%s)", cfg.Fields.Script)
+	}
+
+	var pinyinDict utils.PinyinDict
+	if cfg.Slug.PinyinDict != "" {
+		if pinyinDict, err = utils.LoadPinyinDict(cfg.Slug.PinyinDict); err != nil {
+			return nil, err
+		}
+	}
+	m.slugger = utils.NewSlugger(utils.SlugOptions{
+		Strategy:   cfg.Slug.Strategy,
+		MaxLength:  cfg.Slug.MaxLength,
+		Separator:  cfg.Slug.Separator,
+		PinyinDict: pinyinDict,
+	})
+
+	for _, conf := range cfg.SourceList() {
+		src, err := openSource(conf, cfg.Options.StateFile, cfg.Category.MappingFile)
+		if err != nil {
+			m.Close()
+			return nil, err
+		}
+		m.sources = append(m.sources, src)
+	}
+	m.source = m.sources[0]
+
+	if cfg.I18n.ZhTW != "" {
+		m.zhTW, err = zhconv.New(cfg.I18n.ZhTW, cfg.I18n.ZhTWDict)
+		if err != nil {
+			m.Close()
+			return nil, err
+		}
+		log.Printf("✓ 已启用繁体生成 (%s)", cfg.I18n.ZhTW)
+	}
+
+	m.translator, err = translate.New(cfg.I18n)
+	if err != nil {
+		m.Close()
+		return nil, err
+	}
+	if _, noop := m.translator.(translate.Noop); !noop {
+		log.Printf("✓ 已启用英文翻译 (%s)", cfg.I18n.EnUS)
+	}
+
+	m.client = api.NewClient(cfg.NewAPI.BaseURL, cfg.Options.RetryTimes, cfg.Options.RetryDelay)
+
+	if err := m.client.Login(cfg.NewAPI.Username, cfg.NewAPI.Password); err != nil {
+		m.Close()
 		return nil, fmt.Errorf("登录新版后台失败: %w", err)
 	}
 	log.Println("✓ 新版后台登录成功")
 
-	return &Migrator{
-		cfg:    cfg,
-		db:     db,
-		client: client,
-	}, nil
+	m.probeExternalID()
+	return m, nil
 }
 
 // Close 关闭连接
 func (m *Migrator) Close() {
-	if m.db != nil {
-		m.db.Close()
+	for _, src := range m.sources {
+		src.close()
+	}
+	if cached, ok := m.translator.(*translate.Cached); ok {
+		if err := cached.Save(); err != nil {
+			log.Printf("警告: %v", err)
+		}
 	}
 }
 
@@ -64,6 +156,26 @@ func (m *Migrator) Run() error {
 	log.Println("协议: GPL-3.0")
 	log.Println(strings.Repeat("=", 50))
 
+	for _, src := range m.sources {
+		m.source = src
+		if src.conf.Name != "" {
+			log.Printf("\n########## 站点 %s ##########", src.conf.Name)
+		}
+		if err := m.migrateSource(); err != nil {
+			return fmt.Errorf("%s%w", src.label(), err)
+		}
+	}
+
+	m.printSummary()
+	return nil
+}
+
+// migrateSource 迁移当前站点的分类、商品和卡密
+func (m *Migrator) migrateSource() error {
+	if err := m.prepareFilter(); err != nil {
+		return err
+	}
+
 	categoryMap, err := m.migrateCategories()
 	if err != nil {
 		return fmt.Errorf("迁移分类失败: %w", err)
@@ -79,8 +191,6 @@ func (m *Migrator) Run() error {
 			return fmt.Errorf("迁移卡密失败: %w", err)
 		}
 	}
-
-	m.printSummary()
 	return nil
 }
 
@@ -88,13 +198,13 @@ func (m *Migrator) Run() error {
 func (m *Migrator) migrateCategories() (map[int]map[string]interface{}, error) {
 	log.Println("\n=== 迁移分类 ===")
 
-	where := "deleted_at IS NULL"
-	if m.cfg.Options.OnlyActive {
-		where += " AND is_open = 1"
-	}
+	s := m.schema
+	where, args := m.rowFilter("goods_group")
 
-	query := fmt.Sprintf("SELECT id, gp_name, ord, is_open FROM goods_group WHERE %s ORDER BY ord DESC", where)
-	rows, err := m.db.Query(query)
+	query := s.Rebind(fmt.Sprintf("SELECT %s FROM %s WHERE %s ORDER BY %s DESC",
+		s.Cols("goods_group", "id", "name", "ord", "is_open", "created_at", "updated_at", "deleted_at"),
+		s.Table("goods_group"), where, s.OrderBy("goods_group")))
+	rows, err := m.db.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -103,9 +213,14 @@ func (m *Migrator) migrateCategories() (map[int]map[string]interface{}, error) {
 	var categories []models.Category
 	for rows.Next() {
 		var cat models.Category
-		if err := rows.Scan(&cat.ID, &cat.Name, &cat.Ord, &cat.IsOpen); err != nil {
+		if err := rows.Scan(&cat.ID, &cat.Name, &cat.Ord, &cat.IsOpen,
+			&cat.CreatedAt, &cat.UpdatedAt, &cat.DeletedAt); err != nil {
 			return nil, err
 		}
+		m.observe("goods_group", cat.CreatedAt, cat.UpdatedAt, cat.DeletedAt)
+		if !m.filter.category(cat.ID) {
+			continue
+		}
 		categories = append(categories, cat)
 	}
 
@@ -114,91 +229,251 @@ func (m *Migrator) migrateCategories() (map[int]map[string]interface{}, error) {
 		return make(map[int]map[string]interface{}), nil
 	}
 
-	// 获取已存在的分类
-	existingItems := make(map[string]int)
-	if m.cfg.Options.SkipExisting {
-		existingItems, err = m.getExistingItems("/categories")
+	var rawRows map[int]map[string]interface{}
+	if m.script.Has(script.HookCategory) {
+		if rawRows, err = m.rawRows("goods_group", where, args); err != nil {
+			return nil, fmt.Errorf("读取老版分类数据失败: %w", err)
+		}
+	}
+
+	// 获取已存在的分类，映射规则和父分类也需要按已有分类查找
+	existing := newExistingIndex()
+	if m.cfg.Options.OnExisting != onExistingDuplicate || len(m.categoryMapping.Categories) > 0 || m.conf.ParentCategory != "" {
+		existing, err = m.getExistingItems("/categories", "name")
 		if err != nil {
 			log.Printf("警告: 获取已存在分类失败: %v", err)
 		}
 	}
 
-	maxOrd := 0
+	b := &categoryBatch{
+		existing:  existing,
+		usedSlugs: existing.slugs(),
+		rawRows:   rawRows,
+	}
 	for _, cat := range categories {
-		if cat.Ord > maxOrd {
-			maxOrd = cat.Ord
+		if cat.Ord > b.maxOrd {
+			b.maxOrd = cat.Ord
+		}
+	}
+	if m.syncing {
+		// 增量同步只查询了有变化的分类，排序基准取全表
+		if b.maxOrd, err = m.maxOrd("goods_group"); err != nil {
+			return nil, err
 		}
 	}
 
 	categoryMap := make(map[int]map[string]interface{})
-	usedSlugs := make(map[string]bool)
-	for slug := range existingItems {
-		usedSlugs[slug] = true
+	failedBefore := m.stats.Categories.Failed
+
+	if err := m.ensureParentCategory(existing, b.usedSlugs); err != nil {
+		return nil, err
 	}
 
 	for _, cat := range categories {
-		slug := utils.Slugify(cat.Name)
-		baseSlug := slug
+		if entry := m.migrateCategory(b, cat); entry != nil {
+			categoryMap[cat.ID] = entry
+		}
+	}
 
-		// 检查是否已存在（跳过）
-		if existingID, exists := existingItems[baseSlug]; exists {
-			categoryMap[cat.ID] = map[string]interface{}{
-				"new_id": existingID,
-				"slug":   baseSlug,
+	m.commitCheckpoint("goods_group", m.stats.Categories.Failed == failedBefore)
+	return categoryMap, nil
+}
+
+// categoryBatch 一轮分类迁移共用的数据
+type categoryBatch struct {
+	existing  *existingIndex
+	usedSlugs map[string]bool
+	rawRows   map[int]map[string]interface{} // 转换脚本使用的老版原始行
+	maxOrd    int                            // 排序基准，新版排序值为 maxOrd - ord + 1
+}
+
+// categoryTarget 老版分类在新版中对应的分类
+type categoryTarget struct {
+	name    string   // 新版分类名称（含站点前缀）
+	slug    string   // 按 slug 策略生成的 slug，尚未去重
+	extID   string   // 老版 ID 标记，改名合并时为路径标记
+	path    string   // 分类路径，如 游戏/Steam
+	parents []string // 需要先创建的上级分类
+	grouped bool     // 按路径识别，同一路径只对应一个新版分类
+}
+
+// migrateCategory 迁移一个老版分类，返回 categoryMap 中的记录，未迁移时返回 nil
+func (m *Migrator) migrateCategory(b *categoryBatch, cat models.Category) map[string]interface{} {
+	removed := m.removed(cat.DeletedAt, cat.IsOpen)
+
+	// 分类映射：不迁移、映射到新版已有分类
+	rule, mapped := m.categoryMapping.lookup(cat.ID, cat.Name)
+	if mapped && !removed {
+		if newID, handled := m.applyCategoryRule(cat, rule, b.existing, b.usedSlugs); handled {
+			if newID == 0 {
+				return nil
 			}
-			log.Printf("  ⊘ %s 跳过: 已存在 (ID:%d)", cat.Name, existingID)
-			m.stats.Categories.Skipped++
-			continue
+			m.state.Categories[cat.ID] = newID
+			return map[string]interface{}{"new_id": newID}
 		}
+	}
 
-		slug = utils.EnsureUniqueSlug(slug, usedSlugs)
+	t := m.resolveCategoryTarget(cat, rule, mapped)
+	if id, ok := m.categoryIDs[t.path]; ok && t.grouped && !removed {
+		m.state.Categories[cat.ID] = id
+		log.Printf("  ⇢ %s 合并到 %s (ID:%d)", cat.Name, t.path, id)
+		m.stats.Categories.Mapped++
+		return map[string]interface{}{"new_id": id}
+	}
 
-		payload := map[string]interface{}{
-			"id": 0,
-			"name": map[string]string{
-				"zh-CN": cat.Name,
-				"zh-TW": "",
-				"en-US": "",
-			},
-			"slug":       slug,
-			"sort_order": maxOrd - cat.Ord + 1,
+	// 检查是否已存在
+	item, by, exists := m.findExisting(b.existing, kindCategory, cat.ID, t.extID, t.slug, t.name)
+	if !exists && t.grouped {
+		if found, ok := b.existing.byExternalID[m.mappedExternalID(t.path)]; ok {
+			item, by, exists = found, "path", true
 		}
+	}
 
-		newID, err := m.createWithSlugRetry("/categories", payload, baseSlug, usedSlugs)
-		if err != nil {
-			log.Printf("  ✗ %s 失败: %v", cat.Name, err)
+	if removed {
+		m.handleRemovedCategory(t, item, exists)
+		return nil
+	}
+	return m.saveCategory(b, cat, t, item, by, exists)
+}
+
+// resolveCategoryTarget 按映射规则和层级分隔符确定老版分类在新版的名称、路径和标记
+func (m *Migrator) resolveCategoryTarget(cat models.Category, rule categoryRule, mapped bool) categoryTarget {
+	fullName := cat.Name
+	if mapped && rule.Name != "" {
+		fullName = rule.Name
+	}
+
+	// 层级分类：名称按分隔符拆分（如 游戏/Steam），映射规则的 parent 指定上级分类
+	parents, leaf := m.splitCategoryPath(fullName, rule.Parent)
+	t := categoryTarget{
+		name:    m.conf.CategoryPrefix + leaf,
+		slug:    m.slugFor(kindCategory, cat.ID, leaf),
+		extID:   m.externalID("goods_group", cat.ID),
+		path:    strings.Join(append(parents[:len(parents):len(parents)], leaf), "/"),
+		parents: parents,
+	}
+
+	// 改名合并或层级分类按路径识别：同一路径只对应一个新版分类，老版分类与自动创建的上级分类同名时也复用
+	t.grouped = (mapped && rule.Name != "") || len(parents) > 0 || m.cfg.Category.Delimiter != ""
+	if mapped && rule.Name != "" {
+		t.extID = m.mappedExternalID(t.path)
+	}
+	return t
+}
+
+// handleRemovedCategory 增量同步时老版已删除或停用的分类只提示，不下架或删除：
+// 新版分类下可能还有其他来源或新建的商品，老版分类下的商品会各自按 on_deleted 处理
+func (m *Migrator) handleRemovedCategory(t categoryTarget, item existingItem, exists bool) {
+	if exists {
+		log.Printf("  ⚠ %s 已在老版删除或停用，新版分类保留 (ID:%d)", t.name, item.ID)
+	}
+}
+
+// saveCategory 创建分类，已存在时按 on_existing 跳过或更新；返回 categoryMap 中的记录，失败或跳过时返回 nil
+func (m *Migrator) saveCategory(b *categoryBatch, cat models.Category, t categoryTarget, item existingItem, by string, exists bool) map[string]interface{} {
+	parentID := m.parentID
+	if len(t.parents) > 0 {
+		var err error
+		if parentID, err = m.ensureCategoryPath(b.existing, b.usedSlugs, t.parents); err != nil {
+			log.Printf("  ✗ %s 创建上级分类失败: %v", t.name, err)
 			m.stats.Categories.Failed++
-			continue
+			return nil
 		}
+	}
 
-		categoryMap[cat.ID] = map[string]interface{}{
-			"new_id": newID,
-			"slug":   payload["slug"],
+	if exists {
+		m.state.Categories[cat.ID] = item.ID
+		if t.grouped {
+			m.categoryIDs[t.path] = item.ID
+		}
+		if m.cfg.Options.OnExisting == onExistingSkip {
+			log.Printf("  ⊘ %s 跳过: 已存在 (ID:%d, 按 %s 匹配)", t.name, item.ID, by)
+			m.stats.Categories.Skipped++
+			return map[string]interface{}{"new_id": item.ID, "slug": t.slug}
 		}
-		log.Printf("  ✓ %s (老ID:%d -> 新ID:%d)", cat.Name, cat.ID, newID)
-		m.stats.Categories.Success++
 	}
 
-	return categoryMap, nil
+	payload := map[string]interface{}{
+		"id":          0,
+		"name":        m.localize(t.name),
+		"slug":        t.slug,
+		"sort_order":  b.maxOrd - cat.Ord + 1,
+		"external_id": t.extID,
+	}
+	diffFields := categoryDiffFields
+	if parentID > 0 {
+		payload["parent_id"] = parentID
+		diffFields = append([]string{"parent_id"}, diffFields...)
+	}
+
+	payload, skip, err := m.runScript(script.HookCategory, b.rawRows[cat.ID], payload)
+	if err != nil {
+		log.Printf("  ✗ %s 转换脚本失败: %v", t.name, err)
+		m.stats.Categories.Failed++
+		return nil
+	}
+	if skip {
+		log.Printf("  ⊘ %s 跳过: 转换脚本", t.name)
+		m.stats.Categories.Skipped++
+		return nil
+	}
+
+	if exists {
+		m.updateCategory(t.name, item, payload, diffFields)
+		return map[string]interface{}{"new_id": item.ID, "slug": t.slug}
+	}
+
+	payload["slug"] = utils.EnsureUniqueSlug(t.slug, m.cfg.Slug.Separator, b.usedSlugs)
+
+	newID, err := m.createWithSlugRetry("/categories", payload, t.slug, b.usedSlugs)
+	if err != nil {
+		log.Printf("  ✗ %s 失败: %v", t.name, err)
+		m.stats.Categories.Failed++
+		return nil
+	}
+
+	m.state.Categories[cat.ID] = newID
+	if t.grouped {
+		m.categoryIDs[t.path] = newID
+	}
+	log.Printf("  ✓ %s (老ID:%d -> 新ID:%d)", t.name, cat.ID, newID)
+	m.stats.Categories.Success++
+	return map[string]interface{}{"new_id": newID, "slug": payload["slug"]}
+}
+
+// updateCategory 比较已存在分类的字段，有变化时更新
+func (m *Migrator) updateCategory(name string, item existingItem, payload map[string]interface{}, diffFields []string) {
+	changed, err := m.syncExisting("/categories", item, payload, diffFields)
+	if err != nil {
+		log.Printf("  ✗ %s 更新失败 (ID:%d): %v", name, item.ID, err)
+		m.stats.Categories.Failed++
+	} else if len(changed) == 0 {
+		if !m.syncing {
+			log.Printf("  ⊘ %s 无变化 (ID:%d)", name, item.ID)
+		}
+		m.stats.Categories.Skipped++
+	} else {
+		log.Printf("  ↻ %s 已更新 (ID:%d): %s", name, item.ID, strings.Join(changed, ", "))
+		m.stats.Categories.Updated++
+	}
 }
 
 // migrateProducts 迁移商品
 func (m *Migrator) migrateProducts(categoryMap map[int]map[string]interface{}) (map[int]map[string]interface{}, error) {
 	log.Println("\n=== 迁移商品 ===")
 
-	where := "deleted_at IS NULL"
-	if m.cfg.Options.OnlyActive {
-		where += " AND is_open = 1"
-	}
+	s := m.schema
+	where, args := m.rowFilter("goods")
 
-	query := fmt.Sprintf(`
-		SELECT id, group_id, gd_name, gd_description, gd_keywords, 
-		       picture, actual_price, in_stock, ord, type, 
-		       description, other_ipu_cnf, is_open
-		FROM goods WHERE %s ORDER BY ord DESC
-	`, where)
+	query := s.Rebind(fmt.Sprintf(`
+		SELECT %s
+		FROM %s WHERE %s ORDER BY %s DESC
+	`, s.Cols("goods", "id", "group_id", "name", "description", "keywords",
+		"picture", "actual_price", "in_stock", "ord", "type",
+		"content", "other_ipu_cnf", "api_hook", "is_open", "created_at", "updated_at", "deleted_at"),
+		s.Table("goods"), where, s.OrderBy("goods")))
 
-	rows, err := m.db.Query(query)
+	rows, err := m.db.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -210,10 +485,15 @@ func (m *Migrator) migrateProducts(categoryMap map[int]map[string]interface{}) (
 		if err := rows.Scan(
 			&prod.ID, &prod.GroupID, &prod.Name, &prod.Description, &prod.Keywords,
 			&prod.Picture, &prod.ActualPrice, &prod.InStock, &prod.Ord, &prod.Type,
-			&prod.Content, &prod.OtherIpuCnf, &prod.IsOpen,
+			&prod.Content, &prod.OtherIpuCnf, &prod.APIHook, &prod.IsOpen,
+			&prod.CreatedAt, &prod.UpdatedAt, &prod.DeletedAt,
 		); err != nil {
 			return nil, err
 		}
+		m.observe("goods", prod.CreatedAt, prod.UpdatedAt, prod.DeletedAt)
+		if !m.filter.product(prod.ID) {
+			continue
+		}
 		products = append(products, prod)
 	}
 
@@ -222,44 +502,70 @@ func (m *Migrator) migrateProducts(categoryMap map[int]map[string]interface{}) (
 		return make(map[int]map[string]interface{}), nil
 	}
 
-	existingItems := make(map[string]int)
-	if m.cfg.Options.SkipExisting {
-		existingItems, err = m.getExistingItems("/products")
+	// 字段规则用到老版列或模板、或者有转换脚本时，读取原始行
+	var rawRows map[int]map[string]interface{}
+	if needsRow(m.productFields) || m.script.Has(script.HookProduct) {
+		if rawRows, err = m.rawRows("goods", where, args); err != nil {
+			return nil, fmt.Errorf("读取老版商品数据失败: %w", err)
+		}
+	}
+
+	existing := newExistingIndex()
+	if m.cfg.Options.OnExisting != onExistingDuplicate {
+		existing, err = m.getExistingItems("/products", "title")
 		if err != nil {
 			log.Printf("警告: 获取已存在商品失败: %v", err)
 		}
 	}
 
 	productMap := make(map[int]map[string]interface{})
-	usedSlugs := make(map[string]bool)
-	for slug := range existingItems {
-		usedSlugs[slug] = true
-	}
+	usedSlugs := existing.slugs()
+	failedBefore := m.stats.Products.Failed
 
 	for _, prod := range products {
-		catInfo, exists := categoryMap[prod.GroupID]
-		if !exists {
-			log.Printf("  ⚠ %s 跳过: 分类未迁移", prod.Name)
+		slug := m.slugFor(kindProduct, prod.ID, prod.Name)
+		baseSlug := slug
+		extID := m.externalID("goods", prod.ID)
+
+		item, by, exists := m.findExisting(existing, kindProduct, prod.ID, extID, baseSlug, prod.Name)
+
+		// 增量同步时老版已删除或停用的商品，在新版下架
+		if m.removed(prod.DeletedAt, prod.IsOpen) {
+			if exists {
+				m.removeProduct(prod.ID, prod.Name, item)
+			}
+			continue
+		}
+
+		fulfillmentType := m.fulfillmentType(prod)
+		if fulfillmentType == "skip" {
+			log.Printf("  ⊘ %s 跳过: 商品类型 %d 不迁移", prod.Name, prod.Type)
 			m.stats.Products.Skipped++
 			continue
 		}
 
+		catInfo, ok := categoryMap[prod.GroupID]
+		if !ok {
+			log.Printf("  ⚠ %s 跳过: 分类未迁移", prod.Name)
+			m.stats.Products.Skipped++
+			continue
+		}
 		newCategoryID := toInt(catInfo["new_id"])
-		slug := utils.Slugify(prod.Name)
-		baseSlug := slug
 
-		if existingID, exists := existingItems[baseSlug]; exists {
+		if exists {
+			m.state.Products[prod.ID] = item.ID
+		}
+		if exists && m.cfg.Options.OnExisting == onExistingSkip {
 			productMap[prod.ID] = map[string]interface{}{
-				"new_id": existingID,
-				"slug":   baseSlug,
+				"new_id":   item.ID,
+				"slug":     baseSlug,
+				"existing": true,
 			}
-			log.Printf("  ⊘ %s 跳过: 已存在 (ID:%d)", prod.Name, existingID)
+			log.Printf("  ⊘ %s 跳过: 已存在 (ID:%d, 按 %s 匹配)", prod.Name, item.ID, by)
 			m.stats.Products.Skipped++
 			continue
 		}
 
-		slug = utils.EnsureUniqueSlug(slug, usedSlugs)
-
 		// 处理标签
 		tags := []string{}
 		if prod.Keywords.Valid {
@@ -280,86 +586,88 @@ func (m *Migrator) migrateProducts(categoryMap map[int]map[string]interface{}) (
 			}
 		}
 
-		// 处理发货类型
-		fulfillmentType := "manual"
-		if prod.Type == 1 {
-			fulfillmentType = "auto"
-		}
-
 		// 处理手动发货表单
-		manualFormSchema := map[string]interface{}{
-			"fields": []interface{}{},
-		}
-		if prod.OtherIpuCnf.Valid && prod.Type == 2 {
-			fields := []interface{}{}
-			fieldIndex := 1
-			for _, line := range strings.Split(prod.OtherIpuCnf.String, "\n") {
-				line = strings.TrimSpace(line)
-				if line == "" {
-					continue
-				}
-				parts := strings.Split(line, "|")
-				if len(parts) >= 2 {
-					fieldType := "text"
-					if len(parts) > 3 && parts[3] == "1" {
-						fieldType = "textarea"
-					}
-					required := false
-					if len(parts) > 2 && parts[2] == "1" {
-						required = true
-					}
-					field := map[string]interface{}{
-						"key":      fmt.Sprintf("field%d", fieldIndex),
-						"type":     fieldType,
-						"required": required,
-						"label": map[string]string{
-							"zh-CN": parts[1],
-							"zh-TW": "",
-							"en-US": "",
-						},
-					}
-					fields = append(fields, field)
-					fieldIndex++
-				}
+		var formFields []formField
+		if prod.OtherIpuCnf.Valid && fulfillmentType == "manual" {
+			var warnings []string
+			formFields, warnings = parseFormConfig(prod.OtherIpuCnf.String)
+			for _, w := range warnings {
+				log.Printf("  ⚠ %s 表单配置%s，已跳过该字段", prod.Name, w)
 			}
-			manualFormSchema["fields"] = fields
 		}
+		manualFormSchema := m.formSchema(formFields)
 
 		manualStockTotal := 0
-		if prod.Type == 2 {
+		if fulfillmentType == "manual" {
 			manualStockTotal = prod.InStock
 		}
 
 		payload := map[string]interface{}{
-			"slug":        slug,
-			"category_id": newCategoryID,
-			"title": map[string]string{
-				"zh-CN": prod.Name,
-				"zh-TW": "",
-				"en-US": "",
-			},
-			"description": map[string]string{
-				"zh-CN": nullStr(prod.Description),
-				"zh-TW": "",
-				"en-US": "",
-			},
-			"content": map[string]string{
-				"zh-CN": nullStr(prod.Content),
-				"zh-TW": "",
-				"en-US": "",
-			},
+			"slug":               slug,
+			"category_id":        newCategoryID,
+			"title":              m.localize(prod.Name),
+			"description":        m.localize(nullStr(prod.Description)),
+			"content":            m.localize(nullStr(prod.Content)),
 			"fulfillment_type":   fulfillmentType,
 			"images":             images,
 			"is_active":          true,
 			"manual_form_schema": manualFormSchema,
 			"manual_stock_total": manualStockTotal,
-			"price_amount":       prod.ActualPrice,
-			"price_currency":     "CNY",
+			"price_amount":       priceAmount{m.pricing.convert(prod.ActualPrice)},
+			"price_currency":     m.pricing.currency,
 			"purchase_type":      "guest",
 			"sort_order":         prod.Ord,
 			"tags":               tags,
+			"external_id":        extID,
+		}
+		m.applyAPIHook(prod, payload)
+
+		if len(m.productFields) > 0 {
+			data := rowData(rawRows[prod.ID], prod, payload)
+			if err := m.applyFieldRules(m.productFields, payload, data); err != nil {
+				log.Printf("  ✗ %s 字段规则失败: %v", prod.Name, err)
+				m.stats.Products.Failed++
+				continue
+			}
+		}
+
+		payload, skip, err := m.runScript(script.HookProduct, rawRows[prod.ID], payload)
+		if err != nil {
+			log.Printf("  ✗ %s 转换脚本失败: %v", prod.Name, err)
+			m.stats.Products.Failed++
+			continue
+		}
+		if skip {
+			log.Printf("  ⊘ %s 跳过: 转换脚本", prod.Name)
+			m.stats.Products.Skipped++
+			continue
 		}
 
+		if exists {
+			productMap[prod.ID] = map[string]interface{}{
+				"new_id":   item.ID,
+				"slug":     baseSlug,
+				"existing": true,
+			}
+			changed, err := m.syncExisting("/products", item, payload, m.productCompareFields())
+			if err != nil {
+				log.Printf("  ✗ %s 更新失败 (ID:%d): %v", prod.Name, item.ID, err)
+				m.stats.Products.Failed++
+			} else if len(changed) == 0 {
+				if !m.syncing {
+					log.Printf("  ⊘ %s 无变化 (ID:%d)", prod.Name, item.ID)
+				}
+				m.stats.Products.Skipped++
+			} else {
+				log.Printf("  ↻ %s 已更新 (ID:%d): %s", prod.Name, item.ID, strings.Join(changed, ", "))
+				m.stats.Products.Updated++
+				m.verifyProduct(prod.Name, item.ID, payload)
+			}
+			continue
+		}
+
+		payload["slug"] = utils.EnsureUniqueSlug(slug, m.cfg.Slug.Separator, usedSlugs)
+
 		newID, err := m.createWithSlugRetry("/products", payload, baseSlug, usedSlugs)
 		if err != nil {
 			log.Printf("  ✗ %s 失败: %v", prod.Name, err)
@@ -371,10 +679,13 @@ func (m *Migrator) migrateProducts(categoryMap map[int]map[string]interface{}) (
 			"new_id": newID,
 			"slug":   payload["slug"],
 		}
+		m.state.Products[prod.ID] = newID
 		log.Printf("  ✓ %s (老ID:%d -> 新ID:%d)", prod.Name, prod.ID, newID)
 		m.stats.Products.Success++
+		m.verifyProduct(prod.Name, newID, payload)
 	}
 
+	m.commitCheckpoint("goods", m.stats.Products.Failed == failedBefore)
 	return productMap, nil
 }
 
@@ -382,66 +693,109 @@ func (m *Migrator) migrateProducts(categoryMap map[int]map[string]interface{}) (
 func (m *Migrator) migrateCards(productMap map[int]map[string]interface{}) error {
 	log.Println("\n=== 迁移卡密 ===")
 
+	// 先记录卡密表的修改时间，之后售出或删除的卡密由 sync 同步删除
+	if err := m.initCardCheckpoint(); err != nil {
+		log.Printf("警告: 记录卡密检查点失败: %v", err)
+	}
+
+	failedBefore := m.stats.Cards.Failed
+	maxCardID := 0
+
 	for oldProductID, info := range productMap {
 		newProductID := toInt(info["new_id"])
 
-		query := "SELECT carmi FROM carmis WHERE goods_id = ? AND status = 1 AND deleted_at IS NULL"
-		rows, err := m.db.Query(query, oldProductID)
-		if err != nil {
-			log.Printf("  ✗ 商品%d: 查询卡密失败: %v", newProductID, err)
-			continue
-		}
-
-		var secrets []string
-		for rows.Next() {
-			var carmi string
-			if err := rows.Scan(&carmi); err != nil {
-				log.Printf("  ✗ 商品%d: 读取卡密失败: %v", newProductID, err)
+		// 已存在的商品之前迁移过卡密，只导入上次迁移之后新增的卡密，避免未售出的卡密重复导入
+		minCardID := 0
+		if existing, _ := info["existing"].(bool); existing {
+			if m.state.LastCardID == 0 {
+				log.Printf("  ⊘ 商品%d: 已存在且没有卡密迁移记录，跳过卡密导入", newProductID)
 				continue
 			}
-			secrets = append(secrets, carmi)
+			minCardID = m.state.LastCardID
 		}
-		rows.Close()
 
-		if len(secrets) == 0 {
+		secrets, maxID, err := m.productCards(oldProductID, minCardID)
+		if err != nil {
+			log.Printf("  ✗ 商品%d: 读取卡密失败: %v", newProductID, err)
 			continue
 		}
+		if maxID > maxCardID {
+			maxCardID = maxID
+		}
 
-		batchSize := m.cfg.Options.BatchSize
-		for i := 0; i < len(secrets); i += batchSize {
-			end := i + batchSize
-			if end > len(secrets) {
-				end = len(secrets)
-			}
-			batch := secrets[i:end]
-
-			batchNo := fmt.Sprintf("MIGRATE-%s-%d", time.Now().Format("20060102150405"), oldProductID)
-			payload := map[string]interface{}{
-				"product_id": newProductID,
-				"secrets":    batch,
-				"batch_no":   batchNo,
-				"note":       fmt.Sprintf("从老版迁移 (原商品ID:%d)", oldProductID),
-			}
+		m.importCards(oldProductID, newProductID, secrets)
+	}
 
-			resp, err := m.client.Post("/card-secrets/batch", payload)
-			if err != nil {
-				log.Printf("  ✗ 商品%d: 导入失败: %v", newProductID, err)
-				m.stats.Cards.Failed += len(batch)
-				continue
-			}
+	if m.stats.Cards.Failed == failedBefore && maxCardID > m.state.LastCardID {
+		m.state.LastCardID = maxCardID
+	}
+	return nil
+}
 
-			if resp.StatusCode != 0 {
-				log.Printf("  ✗ 商品%d: 导入失败: %s", newProductID, resp.Msg)
-				m.stats.Cards.Failed += len(batch)
-				continue
-			}
+// productCards 读取商品中 ID 大于 minCardID 的未售卡密（已应用转换脚本），同时返回读取到的最大卡密 ID
+func (m *Migrator) productCards(oldProductID, minCardID int) ([]string, int, error) {
+	s := m.schema
+	query := s.Rebind(fmt.Sprintf("SELECT %s FROM %s WHERE %s = ? AND %s > ? AND %s = 1 AND %s IS NULL",
+		s.Cols("carmis", "id", "carmi"), s.Table("carmis"), s.Col("carmis", "goods_id"),
+		s.Col("carmis", "id"), s.Col("carmis", "status"), s.Col("carmis", "deleted_at")))
+	rows, err := m.db.Query(query, oldProductID, minCardID)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
 
-			m.stats.Cards.Success += len(batch)
-			log.Printf("  ✓ 商品%d: 导入 %d 条卡密", newProductID, len(batch))
+	var secrets []string
+	maxID := 0
+	for rows.Next() {
+		var id int
+		var carmi string
+		if err := rows.Scan(&id, &carmi); err != nil {
+			return nil, 0, err
+		}
+		if id > maxID {
+			maxID = id
+		}
+		if carmi, ok := m.transformCard(id, oldProductID, carmi); ok {
+			secrets = append(secrets, carmi)
 		}
 	}
+	return secrets, maxID, rows.Err()
+}
 
-	return nil
+// importCards 分批导入一个商品的卡密
+func (m *Migrator) importCards(oldProductID, newProductID int, secrets []string) {
+	batchSize := m.cfg.Options.BatchSize
+	for i := 0; i < len(secrets); i += batchSize {
+		end := i + batchSize
+		if end > len(secrets) {
+			end = len(secrets)
+		}
+		batch := secrets[i:end]
+
+		batchNo := fmt.Sprintf("MIGRATE-%s-%d", time.Now().Format("20060102150405"), oldProductID)
+		payload := map[string]interface{}{
+			"product_id": newProductID,
+			"secrets":    batch,
+			"batch_no":   batchNo,
+			"note":       fmt.Sprintf("从老版迁移 (原商品ID:%d)", oldProductID),
+		}
+
+		resp, err := m.client.Post("/card-secrets/batch", payload)
+		if err != nil {
+			log.Printf("  ✗ 商品%d: 导入失败: %v", newProductID, err)
+			m.stats.Cards.Failed += len(batch)
+			continue
+		}
+
+		if resp.StatusCode != 0 {
+			log.Printf("  ✗ 商品%d: 导入失败: %s", newProductID, resp.Msg)
+			m.stats.Cards.Failed += len(batch)
+			continue
+		}
+
+		m.stats.Cards.Success += len(batch)
+		log.Printf("  ✓ 商品%d: 导入 %d 条卡密", newProductID, len(batch))
+	}
 }
 
 // createWithSlugRetry 创建资源，slug 冲突时自动加后缀重试
@@ -453,12 +807,12 @@ func (m *Migrator) createWithSlugRetry(endpoint string, payload map[string]inter
 	}
 
 	if resp.StatusCode == 0 {
-		return extractID(resp)
+		return m.created(endpoint, resp)
 	}
 
 	// slug 冲突，自动加后缀重试
 	for i := 1; i <= 9; i++ {
-		retrySlug := fmt.Sprintf("%s-%d", baseSlug, i)
+		retrySlug := fmt.Sprintf("%s%s%d", baseSlug, m.cfg.Slug.Separator, i)
 		payload["slug"] = retrySlug
 
 		resp, err = m.client.Post(endpoint, payload)
@@ -468,56 +822,22 @@ func (m *Migrator) createWithSlugRetry(endpoint string, payload map[string]inter
 
 		if resp.StatusCode == 0 {
 			usedSlugs[retrySlug] = true
-			return extractID(resp)
+			return m.created(endpoint, resp)
 		}
 	}
 
 	return 0, fmt.Errorf("%s", resp.Msg)
 }
 
-// getExistingItems 获取已存在的项目 {slug: id}
-func (m *Migrator) getExistingItems(endpoint string) (map[string]int, error) {
-	items := make(map[string]int)
-	page := 1
-	maxPages := 100
-
-	for page <= maxPages {
-		resp, err := m.client.Get(fmt.Sprintf("%s?page=%d&page_size=100", endpoint, page))
-		if err != nil {
-			return items, err
-		}
-
-		if resp.StatusCode != 0 {
-			break
-		}
-
-		// API 返回格式可能是 {data: [...]} 或 {data: {data: [...]}}
-		dataList := extractDataList(resp.Data)
-		if len(dataList) == 0 {
-			break
-		}
-
-		beforeCount := len(items)
-		for _, item := range dataList {
-			itemMap, ok := item.(map[string]interface{})
-			if !ok {
-				continue
-			}
-			if slug, ok := itemMap["slug"].(string); ok {
-				if id, ok := itemMap["id"].(float64); ok {
-					items[slug] = int(id)
-				}
-			}
+// created 返回新建数据的 ID；还没有确认新版是否返回 external_id 时读取新建的数据检查
+func (m *Migrator) created(endpoint string, resp *api.Response) (int, error) {
+	id, err := extractID(resp)
+	if err == nil && !m.externalIDKnown {
+		if detail := m.fetchDetail(endpoint, id); detail != nil {
+			m.checkExternalID(detail)
 		}
-
-		if len(items) == beforeCount {
-			break
-		}
-
-		page++
 	}
-
-	return items, nil
+	return id, err
 }
 
 // printSummary 打印统计信息
@@ -525,12 +845,20 @@ func (m *Migrator) printSummary() {
 	log.Println("\n" + strings.Repeat("=", 50))
 	log.Println("迁移统计")
 	log.Println(strings.Repeat("=", 50))
-	log.Printf("分类: 成功 %d, 跳过 %d, 失败 %d",
-		m.stats.Categories.Success, m.stats.Categories.Skipped, m.stats.Categories.Failed)
-	log.Printf("商品: 成功 %d, 跳过 %d, 失败 %d",
-		m.stats.Products.Success, m.stats.Products.Skipped, m.stats.Products.Failed)
-	log.Printf("卡密: 成功 %d, 失败 %d",
-		m.stats.Cards.Success, m.stats.Cards.Failed)
+	log.Printf("分类: 成功 %d, 更新 %d, 合并 %d, 不迁移 %d, 跳过 %d, 失败 %d",
+		m.stats.Categories.Success, m.stats.Categories.Updated, m.stats.Categories.Mapped,
+		m.stats.Categories.Dropped, m.stats.Categories.Skipped, m.stats.Categories.Failed)
+	log.Printf("商品: 成功 %d, 更新 %d, 下架 %d, 删除 %d, 跳过 %d, 失败 %d",
+		m.stats.Products.Success, m.stats.Products.Updated, m.stats.Products.Deactivated,
+		m.stats.Products.Deleted, m.stats.Products.Skipped, m.stats.Products.Failed)
+	log.Printf("卡密: 成功 %d, 删除 %d, 跳过 %d, 失败 %d",
+		m.stats.Cards.Success, m.stats.Cards.Removed, m.stats.Cards.Skipped, m.stats.Cards.Failed)
+	if n := m.stats.Products.HookSkipped; n > 0 {
+		log.Printf("警告: %d 个商品的 api_hook 未迁移，请在新版手动配置回调", n)
+	}
+	if n := m.stats.Products.PriceMismatch; n > 0 {
+		log.Printf("警告: %d 个商品的新版价格与迁移价格不一致，见上方「价格不一致」", n)
+	}
 	log.Println(strings.Repeat("=", 50))
 }
 
@@ -581,6 +909,26 @@ func toInt(v interface{}) int {
 	}
 }
 
+// localize 生成多语言字段，zh-TW 和 en-US 按配置由简体转换/翻译
+func (m *Migrator) localize(text string) map[string]string {
+	zhTW, enUS := "", ""
+	if text != "" {
+		if m.zhTW != nil {
+			zhTW = m.zhTW.Convert(text)
+		}
+		en, err := m.translator.Translate(text)
+		if err != nil {
+			log.Printf("    ⚠ 翻译失败: %v", err)
+		}
+		enUS = en
+	}
+	return map[string]string{
+		"zh-CN": text,
+		"zh-TW": zhTW,
+		"en-US": enUS,
+	}
+}
+
 // nullStr 安全地获取 sql.NullString 的值
 func nullStr(ns sql.NullString) string {
 	if ns.Valid {
@@ -590,37 +938,44 @@ func nullStr(ns sql.NullString) string {
 }
 
 // uploadImage 上传图片到新版 API，返回新 URL
-// 支持本地文件路径和 HTTP URL
+// 支持本地文件路径和 HTTP URL，无法上传时返回老版图片地址
 func (m *Migrator) uploadImage(picturePath string) string {
 	if picturePath == "" {
 		return ""
 	}
 
-	oldSitePath := m.cfg.Options.OldSitePath
-	if oldSitePath == "" {
-		// 没配置老版站点路径，直接返回原始 URL
+	// 如果是完整 URL（http/https），远程 URL 暂不处理，直接返回
+	if strings.HasPrefix(picturePath, "http://") || strings.HasPrefix(picturePath, "https://") {
 		return picturePath
 	}
 
-	// 如果是完整 URL（http/https），尝试下载后上传
-	if strings.HasPrefix(picturePath, "http://") || strings.HasPrefix(picturePath, "https://") {
-		// 远程 URL 暂不处理，直接返回
-		return picturePath
+	// 已上传过的图片直接复用
+	if newURL, ok := m.state.Images[picturePath]; ok {
+		return newURL
+	}
+
+	fallback := m.imageURL(picturePath)
+
+	oldSitePath := m.conf.OldSitePath
+	if oldSitePath == "" {
+		// 没配置老版站点路径，直接返回原始地址
+		return fallback
 	}
 
 	// 拼接本地文件路径
-	// 老版图片一般在 public/ 目录下
+	// 老版图片一般在 public/uploads 目录下
 	localPath := picturePath
-	if !filepath.IsAbs(picturePath) {
+	if !m.files.IsAbs(picturePath) {
 		// 尝试多个可能的路径
 		candidates := []string{
-			filepath.Join(oldSitePath, "public", picturePath),
-			filepath.Join(oldSitePath, picturePath),
-			filepath.Join(oldSitePath, "public", "storage", picturePath),
+			m.files.Join(oldSitePath, "public", "uploads", picturePath),
+			m.files.Join(oldSitePath, "public", picturePath),
+			m.files.Join(oldSitePath, picturePath),
+			m.files.Join(oldSitePath, "public", "storage", picturePath),
 		}
 		found := false
 		for _, p := range candidates {
-			if _, err := os.Stat(p); err == nil {
+			if _, err := m.files.Stat(p); err == nil {
 				localPath = p
 				found = true
 				break
@@ -628,39 +983,53 @@ func (m *Migrator) uploadImage(picturePath string) string {
 		}
 		if !found {
 			log.Printf("    ⚠ 图片文件不存在: %s", picturePath)
-			return picturePath
+			return fallback
 		}
 	}
 
-	// 检查文件是否存在
-	if _, err := os.Stat(localPath); os.IsNotExist(err) {
-		log.Printf("    ⚠ 图片文件不存在: %s", localPath)
-		return picturePath
+	// 读取图片文件
+	data, err := m.files.ReadFile(localPath)
+	if err != nil {
+		log.Printf("    ⚠ 图片文件读取失败: %v", err)
+		return fallback
 	}
 
 	// 上传到新版 API
-	resp, err := m.client.UploadFile(localPath)
+	resp, err := m.client.UploadBytes(path.Base(localPath), data)
 	if err != nil {
 		log.Printf("    ⚠ 图片上传失败: %v", err)
-		return picturePath
+		return fallback
 	}
 
 	if resp.StatusCode != 0 {
 		log.Printf("    ⚠ 图片上传失败: %s", resp.Msg)
-		return picturePath
+		return fallback
 	}
 
 	// 解析返回的 URL
 	dataMap, ok := resp.Data.(map[string]interface{})
 	if !ok {
 		log.Printf("    ⚠ 图片上传响应格式错误")
-		return picturePath
+		return fallback
 	}
 
 	if newURL, ok := dataMap["url"].(string); ok {
 		log.Printf("    📷 图片上传成功: %s", newURL)
+		m.state.Images[picturePath] = newURL
 		return newURL
 	}
 
-	return picturePath
+	return fallback
+}
+
+// imageURL 将老版相对图片路径转换为可访问的完整地址
+func (m *Migrator) imageURL(picturePath string) string {
+	prefix := m.cfg.Options.ImageURLPrefix
+	if prefix == "" {
+		prefix = m.imagePrefix
+	}
+	if prefix == "" {
+		return picturePath
+	}
+	return strings.TrimRight(prefix, "/") + "/" + strings.TrimLeft(picturePath, "/")
 }
@@ -0,0 +1,151 @@
+package migrator
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/shopspring/decimal"
+	"gopkg.in/yaml.v3"
+
+	"github.com/luoyanglang/dujiao-migrate/internal/config"
+)
+
+// pricing 价格换算：按汇率换算为新版货币后取整，使用十进制运算避免浮点误差
+type pricing struct {
+	currency string          // 新版价格货币
+	rate     decimal.Decimal // 1 单位老版货币可兑换的新版货币
+	rounding string
+}
+
+// newPricing 确定汇率，rates 优先，其次 rates_file；货币相同时汇率为 1
+func newPricing(cfg config.PriceConfig) (*pricing, error) {
+	p := &pricing{currency: cfg.Currency, rate: decimal.NewFromInt(1), rounding: cfg.Rounding}
+	if cfg.Currency == cfg.SourceCurrency {
+		return p, nil
+	}
+
+	rate, ok := cfg.Rates[cfg.Currency]
+	if !ok && cfg.RatesFile != "" {
+		rates, err := loadRatesFile(cfg.RatesFile, cfg.SourceCurrency)
+		if err != nil {
+			return nil, err
+		}
+		rate, ok = rates[cfg.Currency]
+	}
+	if !ok {
+		return nil, fmt.Errorf("没有 %s -> %s 的汇率", cfg.SourceCurrency, cfg.Currency)
+	}
+
+	var err error
+	if p.rate, err = decimal.NewFromString(strings.TrimSpace(rate)); err != nil || !p.rate.IsPositive() {
+		return nil, fmt.Errorf("%s 的汇率 %q 无效", cfg.Currency, rate)
+	}
+	return p, nil
+}
+
+// loadRatesFile 读取汇率文件，支持两种格式（JSON 同样适用）：
+//
+//	USD: 0.1389             # 货币 -> 汇率
+//	EUR: 0.1275
+//
+//	base: CNY               # 汇率接口常见格式，base 须与 source_currency 相同
+//	rates: {USD: 0.1389}
+func loadRatesFile(path, base string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取汇率文件失败: %w", err)
+	}
+
+	var wrapped struct {
+		Base  string            `yaml:"base"`
+		Rates map[string]string `yaml:"rates"`
+	}
+	if err := yaml.Unmarshal(data, &wrapped); err == nil && len(wrapped.Rates) > 0 {
+		if wrapped.Base != "" && !strings.EqualFold(wrapped.Base, base) {
+			return nil, fmt.Errorf("汇率文件的 base=%s 与 price.source_currency=%s 不同", wrapped.Base, base)
+		}
+		return wrapped.Rates, nil
+	}
+
+	var rates map[string]string
+	if err := yaml.Unmarshal(data, &rates); err != nil {
+		return nil, fmt.Errorf("解析汇率文件失败: %w", err)
+	}
+	return rates, nil
+}
+
+// convert 换算并取整
+//
+//	cents  四舍五入到分
+//	ceil   向上取整到元
+//	99     以 .99 结尾：向上取整到元后减 0.01，如 13.20 -> 13.99，13.00 -> 12.99，免费商品不变
+//	none   不取整
+func (p *pricing) convert(amount decimal.Decimal) decimal.Decimal {
+	v := amount.Mul(p.rate)
+	switch p.rounding {
+	case "cents":
+		return v.Round(2)
+	case "ceil":
+		return v.Ceil()
+	case "99":
+		if !v.IsPositive() {
+			return v
+		}
+		return v.Ceil().Sub(decimal.New(1, -2))
+	}
+	return v
+}
+
+// priceAmount 价格，JSON 中为不带引号的精确数字（如 9.9），不经过 float64
+type priceAmount struct {
+	decimal.Decimal
+}
+
+// MarshalJSON 输出精确数字
+func (p priceAmount) MarshalJSON() ([]byte, error) {
+	return []byte(p.String()), nil
+}
+
+// toDecimal 转换为十进制数，支持数字、数字字符串和 json.Number；float64 按最短表示转换（0.1 即 0.1）
+func toDecimal(v interface{}) (decimal.Decimal, error) {
+	switch n := v.(type) {
+	case decimal.Decimal:
+		return n, nil
+	case priceAmount:
+		return n.Decimal, nil
+	case float64:
+		return decimal.NewFromFloat(n), nil
+	case float32:
+		return decimal.NewFromFloat32(n), nil
+	case int:
+		return decimal.NewFromInt(int64(n)), nil
+	case int64:
+		return decimal.NewFromInt(n), nil
+	case nil:
+		return decimal.Zero, nil
+	}
+	s := strings.TrimSpace(fmt.Sprint(v))
+	if s == "" {
+		return decimal.Zero, nil
+	}
+	d, err := decimal.NewFromString(s)
+	if err != nil {
+		return decimal.Zero, fmt.Errorf("不是有效的数字: %q", s)
+	}
+	return d, nil
+}
+
+// checkPrice 检查新版保存的价格与迁移的价格是否一致（如新版按 float 存储或截断小数位）
+func (m *Migrator) checkPrice(name string, id int, payload, detail map[string]interface{}) {
+	want, err := toDecimal(payload["price_amount"])
+	if err != nil {
+		return
+	}
+	got, err := toDecimal(detail["price_amount"])
+	if err != nil || !got.Equal(want) {
+		log.Printf("  ⚠ %s 价格不一致 (ID:%d): 迁移 %s，新版保存为 %v", name, id, want, detail["price_amount"])
+		m.stats.Products.PriceMismatch++
+	}
+}
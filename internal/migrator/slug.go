@@ -0,0 +1,153 @@
+package migrator
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// slug 类型前缀，用于 id/hash 策略和覆盖文件
+const (
+	kindCategory = "c"
+	kindProduct  = "p"
+)
+
+// slugOverrides 手动指定的 slug（类型 -> [站点名称:]老版 ID 或名称 -> slug）
+type slugOverrides map[string]map[string]string
+
+// loadSlugOverrides 加载 slug 覆盖文件
+//
+// YAML 格式:
+//
+//	categories:
+//	  "3": steam           # 按老版 ID
+//	  "游戏点卡": game-cards  # 按名称
+//	products:
+//	  "10": windows-11-pro
+//	  "shop-b:10": office   # 多站点合并时带站点名称
+//
+// CSV 格式（每行: 类型,老版ID或名称,slug，类型为 category 或 product）:
+//
+//	category,3,steam
+//	product,Windows 11 专业版,windows-11-pro
+//
+// sources 为站点名称；多站点合并时同一个老版 ID 在各站点是不同的数据，key 必须带站点名称
+func loadSlugOverrides(path string, sources []string) (slugOverrides, error) {
+	overrides := slugOverrides{
+		kindCategory: make(map[string]string),
+		kindProduct:  make(map[string]string),
+	}
+	if path == "" {
+		return overrides, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取 slug 覆盖文件失败: %w", err)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		reader := csv.NewReader(strings.NewReader(string(data)))
+		reader.FieldsPerRecord = -1
+		reader.Comment = '#'
+		reader.TrimLeadingSpace = true
+		records, err := reader.ReadAll()
+		if err != nil {
+			return nil, fmt.Errorf("解析 slug 覆盖文件失败: %w", err)
+		}
+		for i, record := range records {
+			if len(record) < 3 {
+				return nil, fmt.Errorf("slug 覆盖文件第 %d 行格式错误，应为: 类型,老版ID或名称,slug", i+1)
+			}
+			kind, err := overrideKind(record[0])
+			if err != nil {
+				if i == 0 {
+					continue // 表头
+				}
+				return nil, fmt.Errorf("slug 覆盖文件第 %d 行: %w", i+1, err)
+			}
+			overrides[kind][strings.TrimSpace(record[1])] = strings.TrimSpace(record[2])
+		}
+
+	default:
+		var file struct {
+			Categories map[string]string `yaml:"categories"`
+			Products   map[string]string `yaml:"products"`
+		}
+		if err := yaml.Unmarshal(data, &file); err != nil {
+			return nil, fmt.Errorf("解析 slug 覆盖文件失败: %w", err)
+		}
+		for key, slug := range file.Categories {
+			overrides[kindCategory][strings.TrimSpace(key)] = slug
+		}
+		for key, slug := range file.Products {
+			overrides[kindProduct][strings.TrimSpace(key)] = slug
+		}
+	}
+
+	if len(sources) > 1 {
+		var bare []string
+		for _, kind := range []string{kindCategory, kindProduct} {
+			for key := range overrides[kind] {
+				if source, _, ok := strings.Cut(key, ":"); !ok || !contains(sources, source) {
+					bare = append(bare, key)
+				}
+			}
+		}
+		if len(bare) > 0 {
+			sort.Strings(bare)
+			return nil, fmt.Errorf("多站点合并时 slug 覆盖文件的 key 需要带站点名称（如 %s:12），以下 key 没有: %s",
+				sources[0], strings.Join(bare, ", "))
+		}
+	}
+	return overrides, nil
+}
+
+func overrideKind(s string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "category", "categories", "c":
+		return kindCategory, nil
+	case "product", "products", "p":
+		return kindProduct, nil
+	default:
+		return "", fmt.Errorf("未知类型 %q，应为 category 或 product", s)
+	}
+}
+
+// lookup 查找站点 source 手动指定的 slug，老版 ID 优先于名称；带站点名称的 key 优先于不带的
+func (o slugOverrides) lookup(kind, source string, id int, name string) (string, bool) {
+	keys := []string{strconv.Itoa(id), strings.TrimSpace(name)}
+	if source != "" {
+		keys = append([]string{source + ":" + keys[0], source + ":" + keys[1]}, keys...)
+	}
+	for _, key := range keys {
+		if slug, ok := o[kind][key]; ok && slug != "" {
+			return slug, true
+		}
+	}
+	return "", false
+}
+
+// slugFor 生成 slug：优先使用覆盖文件，否则按配置的策略生成；都加上站点的 slug_prefix
+func (m *Migrator) slugFor(kind string, id int, name string) string {
+	if slug, ok := m.slugOverrides.lookup(kind, m.conf.Name, id, name); ok {
+		return m.conf.SlugPrefix + slug
+	}
+	return m.conf.SlugPrefix + m.slugger.Make(name, kind, id)
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
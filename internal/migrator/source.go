@@ -0,0 +1,188 @@
+package migrator
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+
+	"github.com/luoyanglang/dujiao-migrate/internal/config"
+	"github.com/luoyanglang/dujiao-migrate/internal/database"
+)
+
+// source 一个老版站点的连接和迁移状态
+//
+// 合并多个站点时按配置顺序依次迁移，每个站点有独立的 ID 映射和同步检查点；
+// 先迁移的站点先占用 slug，后迁移的站点遇到冲突时自动加后缀
+type source struct {
+	conf config.SourceConfig
+
+	db      *sql.DB
+	schema  *database.Schema
+	state   *migrateState        // 迁移状态（图片上传记录、ID 映射、同步检查点）
+	pending map[string]time.Time // 本轮读取到的最大修改时间（数据表 -> 时间）
+
+	tunnel *ssh.Client  // SSH 隧道，未启用时为 nil
+	sftp   *sftp.Client // SFTP 客户端，未启用时为 nil
+	files  siteFiles    // 老版站点文件访问
+
+	imagePrefix string // 远程 .env 中 APP_URL 生成的图片地址前缀，未设置 image_url_prefix 时使用
+
+	parentID int // 新版父分类 ID，未设置 parent_category 时为 0
+
+	categoryMapping *categoryMapping // 分类映射规则
+	defaultID       int              // 不迁移的分类下商品归入的新版分类 ID
+	categoryIDs     map[string]int   // 按名称合并的分类和上级分类（路径 -> 新版分类 ID）
+}
+
+// openSource 连接老版站点并加载迁移状态
+func openSource(conf config.SourceConfig, stateFile, mappingFile string) (*source, error) {
+	src := &source{
+		conf:    conf,
+		files:   localFiles{},
+		pending: make(map[string]time.Time),
+
+		categoryIDs: make(map[string]int),
+	}
+
+	var err error
+	if src.state, err = loadState(sourceStateFile(stateFile, conf.Name)); err != nil {
+		return nil, err
+	}
+	if conf.CategoryMapping != "" {
+		mappingFile = conf.CategoryMapping
+	}
+	if src.categoryMapping, err = loadCategoryMapping(mappingFile); err != nil {
+		return nil, err
+	}
+
+	db := conf.OldDB
+	if db.SSH.Enabled() {
+		tunnel, err := database.DialSSH(db.SSH)
+		if err != nil {
+			return nil, err
+		}
+		src.tunnel = tunnel
+		log.Printf("✓ %sSSH 隧道连接成功 (%s)", src.label(), db.SSH.Host)
+
+		if db.SSH.SFTP {
+			client, err := sftp.NewClient(tunnel)
+			if err != nil {
+				src.close()
+				return nil, fmt.Errorf("建立 SFTP 会话失败: %w", err)
+			}
+			src.sftp = client
+			src.files = sftpFiles{client: client}
+
+			if err := src.applyRemoteEnv(&db); err != nil {
+				src.close()
+				return nil, err
+			}
+		}
+	}
+
+	if src.db, err = database.Connect(db, src.tunnel); err != nil {
+		src.close()
+		return nil, fmt.Errorf("连接%s老版数据库失败: %w", src.label(), err)
+	}
+	log.Printf("✓ %s老版数据库连接成功", src.label())
+
+	if src.schema, err = database.DetectSchema(src.db, db.Driver, db.TablePrefix); err != nil {
+		src.close()
+		return nil, err
+	}
+	log.Printf("✓ %s检测到数据库结构: %s (表前缀: %q)", src.label(), src.schema.Variant, src.schema.Prefix)
+
+	return src, nil
+}
+
+// applyRemoteEnv 通过 SFTP 读取老版站点的 .env，填充仍为默认值的数据库配置
+// 未设置 old_site_path 或 .env 不存在时不做任何修改
+func (src *source) applyRemoteEnv(db *config.DBConfig) error {
+	if src.conf.OldSitePath == "" {
+		return nil
+	}
+	envPath := src.files.Join(src.conf.OldSitePath, ".env")
+	data, err := src.files.ReadFile(envPath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("读取%s老版站点 .env 失败: %w", src.label(), err)
+	}
+
+	env, err := config.ParseDotEnvData(data, envPath)
+	if err != nil {
+		return fmt.Errorf("读取%s老版站点 .env 失败: %w", src.label(), err)
+	}
+	if src.imagePrefix, err = config.ApplyLaravelEnv(db, env, envPath, src.conf.OldSitePath); err != nil {
+		return err
+	}
+	log.Printf("✓ %s已读取老版站点 .env (%s)", src.label(), envPath)
+	return nil
+}
+
+// label 日志中的站点标识，单站点时为空
+func (src *source) label() string {
+	if src.conf.Name == "" {
+		return ""
+	}
+	return "[" + src.conf.Name + "] "
+}
+
+// externalID 老版数据标记，如 dujiaoka:goods:12，多站点时带站点名称，如 dujiaoka:shop-a:goods:12
+func (src *source) externalID(table string, id int) string {
+	if src.conf.Name == "" {
+		return externalID(table, id)
+	}
+	return fmt.Sprintf("dujiaoka:%s:%s:%d", src.conf.Name, table, id)
+}
+
+// close 保存状态并关闭连接
+func (src *source) close() {
+	if src.state != nil {
+		if err := src.state.save(); err != nil {
+			log.Printf("警告: %v", err)
+		}
+	}
+	if src.db != nil {
+		src.db.Close()
+	}
+	if src.sftp != nil {
+		src.sftp.Close()
+	}
+	if src.tunnel != nil {
+		src.tunnel.Close()
+	}
+}
+
+// sourceStateFile 多站点时每个站点使用单独的状态文件，如 migrate-state.shop-a.json
+func sourceStateFile(stateFile, name string) string {
+	if stateFile == "" || name == "" {
+		return stateFile
+	}
+	ext := filepath.Ext(stateFile)
+	return strings.TrimSuffix(stateFile, ext) + "." + name + ext
+}
+
+// ensureParentCategory 查找或创建站点的 parent_category，按站点标记或名称匹配新版已有分类
+func (m *Migrator) ensureParentCategory(existing *existingIndex, usedSlugs map[string]bool) error {
+	name := m.conf.ParentCategory
+	if name == "" || m.parentID > 0 {
+		return nil
+	}
+
+	id, err := m.ensureCategory(existing, usedSlugs, name, "dujiaoka:"+m.conf.Name, 0)
+	if err != nil {
+		return fmt.Errorf("创建父分类 %s 失败: %w", name, err)
+	}
+	m.parentID = id
+	return nil
+}
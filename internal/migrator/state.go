@@ -0,0 +1,86 @@
+package migrator
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// migrateState 迁移状态，保存在 state_file 中，重复运行时复用
+type migrateState struct {
+	path string
+
+	Images map[string]string `json:"images"` // 老版图片路径 -> 新版 URL
+
+	Categories map[int]int `json:"categories"` // 老版分类 ID -> 新版分类 ID
+	Products   map[int]int `json:"products"`   // 老版商品 ID -> 新版商品 ID
+
+	Checkpoints map[string]time.Time `json:"checkpoints"`  // 数据表 -> 已同步到的修改时间（RFC3339）
+	LastCardID  int                  `json:"last_card_id"` // 已导入的最大卡密 ID
+
+	RemovedCards map[int]bool `json:"removed_cards,omitempty"` // 已从新版删除（或新版已售出）的老版卡密 ID
+}
+
+// loadState 加载迁移状态，path 为空时只保存在内存中
+func loadState(path string) (*migrateState, error) {
+	st := &migrateState{path: path}
+
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("读取状态文件失败: %w", err)
+		}
+		if len(data) > 0 {
+			if err := json.Unmarshal(data, st); err != nil {
+				return nil, fmt.Errorf("解析状态文件失败: %w", err)
+			}
+		}
+	}
+
+	if st.Images == nil {
+		st.Images = make(map[string]string)
+	}
+	if st.Categories == nil {
+		st.Categories = make(map[int]int)
+	}
+	if st.Products == nil {
+		st.Products = make(map[int]int)
+	}
+	if st.Checkpoints == nil {
+		st.Checkpoints = make(map[string]time.Time)
+	}
+	if st.RemovedCards == nil {
+		st.RemovedCards = make(map[int]bool)
+	}
+	return st, nil
+}
+
+// save 写入状态文件
+func (st *migrateState) save() error {
+	if st.path == "" {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if dir := filepath.Dir(st.path); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("创建状态文件目录失败: %w", err)
+		}
+	}
+
+	// 先写临时文件再重命名，避免中断时损坏状态
+	tmp := st.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("写入状态文件失败: %w", err)
+	}
+	if err := os.Rename(tmp, st.path); err != nil {
+		return fmt.Errorf("写入状态文件失败: %w", err)
+	}
+	return nil
+}
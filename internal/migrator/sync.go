@@ -0,0 +1,616 @@
+package migrator
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/luoyanglang/dujiao-migrate/internal/models"
+)
+
+// Sync 增量同步：只处理上次同步后老版有变化的分类、商品和新增卡密
+// watch 为 true 时每隔 interval 同步一次，直到 ctx 取消
+func (m *Migrator) Sync(ctx context.Context, watch bool, interval time.Duration) error {
+	if m.cfg.Options.StateFile == "" {
+		return fmt.Errorf("增量同步需要设置 options.state_file，用于保存同步检查点")
+	}
+	for _, src := range m.sources {
+		if !src.schema.Has("goods", "updated_at") && !src.schema.Has("goods", "created_at") {
+			return fmt.Errorf("%s老版商品表缺少 updated_at 和 created_at 字段，无法判断哪些数据有变化", src.label())
+		}
+	}
+
+	// 同步模式下已存在的数据总是按字段更新
+	m.syncing = true
+	m.cfg.Options.OnExisting = onExistingUpdate
+
+	for round := 1; ; round++ {
+		log.Printf("\n===== 第 %d 轮同步 (%s) =====", round, time.Now().Format("2006-01-02 15:04:05"))
+		m.stats = models.Stats{}
+
+		if err := m.syncOnce(); err != nil {
+			if !watch {
+				return err
+			}
+			log.Printf("✗ 本轮同步失败: %v", err)
+		}
+
+		if !watch {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			log.Println("收到退出信号，停止同步")
+			return nil
+		case <-time.After(interval):
+		}
+	}
+}
+
+// syncOnce 执行一轮增量同步，按顺序同步各站点，某个站点失败不影响其他站点
+func (m *Migrator) syncOnce() error {
+	var errs []error
+	for _, src := range m.sources {
+		m.source = src
+		if src.conf.Name != "" {
+			log.Printf("\n########## 站点 %s ##########", src.conf.Name)
+		}
+
+		err := m.syncSource()
+		if saveErr := src.state.save(); saveErr != nil {
+			log.Printf("警告: %v", saveErr)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s%w", src.label(), err))
+		}
+	}
+
+	m.printSummary()
+	return errors.Join(errs...)
+}
+
+// syncSource 增量同步当前站点
+func (m *Migrator) syncSource() error {
+	m.pending = make(map[string]time.Time)
+
+	if err := m.prepareFilter(); err != nil {
+		return err
+	}
+	if err := m.checkRemovals(); err != nil {
+		return err
+	}
+
+	categoryMap, err := m.migrateCategories()
+	if err != nil {
+		return fmt.Errorf("同步分类失败: %w", err)
+	}
+	// 本轮没有变化的分类使用之前记录的 ID 映射
+	for oldID, newID := range m.state.Categories {
+		if _, ok := categoryMap[oldID]; !ok {
+			categoryMap[oldID] = map[string]interface{}{"new_id": newID}
+		}
+	}
+
+	productMap, err := m.migrateProducts(categoryMap)
+	if err != nil {
+		return fmt.Errorf("同步商品失败: %w", err)
+	}
+
+	if m.cfg.Options.MigrateCards {
+		for oldID, newID := range m.state.Products {
+			if _, ok := productMap[oldID]; !ok {
+				productMap[oldID] = map[string]interface{}{"new_id": newID, "existing": true}
+			}
+		}
+		if err := m.syncCards(productMap); err != nil {
+			return fmt.Errorf("同步卡密失败: %w", err)
+		}
+		if err := m.syncRemovedCards(); err != nil {
+			return fmt.Errorf("同步已售出卡密失败: %w", err)
+		}
+	}
+	return nil
+}
+
+// baseFilter 全量迁移的查询条件：排除已删除的数据，only_active 时排除已停用的数据
+func (m *Migrator) baseFilter(table string) string {
+	s := m.schema
+	where := s.Col(table, "deleted_at") + " IS NULL"
+	if m.cfg.Options.OnlyActive {
+		where += " AND " + s.Col(table, "is_open") + " = 1"
+	}
+	return where
+}
+
+// rowFilter 返回分类或商品的查询条件，包含 filter 配置的过滤条件
+func (m *Migrator) rowFilter(table string) (string, []interface{}) {
+	where, args := m.changeFilter(table)
+	if cond, condArgs := m.filterConditions(table); cond != "" {
+		where += " AND " + cond
+		args = append(args, condArgs...)
+	}
+	return where, args
+}
+
+// changeFilter 增量同步时只查询检查点之后新增、修改或删除的数据（包含已删除和已停用的数据，用于同步下架）；
+// 首次同步或数据表没有时间字段时查询全表
+func (m *Migrator) changeFilter(table string) (string, []interface{}) {
+	if !m.syncing {
+		return m.baseFilter(table), nil
+	}
+
+	checkpoint := m.state.Checkpoints[table]
+	if checkpoint.IsZero() {
+		return "1 = 1", nil
+	}
+
+	s := m.schema
+	var conds []string
+	var args []interface{}
+	for _, field := range []string{"created_at", "updated_at", "deleted_at"} {
+		if s.Has(table, field) {
+			// 使用 >= 重复处理检查点所在的那一秒，避免同一秒内后写入的数据被漏掉
+			conds = append(conds, s.Col(table, field)+" >= ?")
+			args = append(args, s.TimeArg(checkpoint))
+		}
+	}
+	if len(conds) == 0 {
+		return "1 = 1", nil
+	}
+	return "(" + strings.Join(conds, " OR ") + ")", args
+}
+
+// removed 判断数据在老版是否已删除或停用（only_active 时）
+func (m *Migrator) removed(deletedAt models.NullTime, isOpen int) bool {
+	return deletedAt.Valid || (m.cfg.Options.OnlyActive && isOpen != 1)
+}
+
+// maxOrd 全量迁移范围内的最大排序值
+func (m *Migrator) maxOrd(table string) (int, error) {
+	s := m.schema
+	var maxOrd sql.NullInt64
+	query := fmt.Sprintf("SELECT MAX(%s) FROM %s WHERE %s", s.Col(table, "ord"), s.Table(table), m.baseFilter(table))
+	if err := m.db.QueryRow(query).Scan(&maxOrd); err != nil {
+		return 0, err
+	}
+	return int(maxOrd.Int64), nil
+}
+
+// observe 记录本轮读取到的数据行的修改时间
+func (m *Migrator) observe(table string, stamps ...models.NullTime) {
+	for _, stamp := range stamps {
+		if stamp.Valid && stamp.Time.After(m.pending[table]) {
+			m.pending[table] = stamp.Time
+		}
+	}
+}
+
+// commitCheckpoint 推进同步检查点；本轮有失败时不推进，下一轮重新处理
+func (m *Migrator) commitCheckpoint(table string, ok bool) {
+	if ok && m.pending[table].After(m.state.Checkpoints[table]) {
+		m.state.Checkpoints[table] = m.pending[table]
+	}
+}
+
+// findExisting 查找老版数据对应的新版数据，优先使用状态文件中记录的 ID 映射
+func (m *Migrator) findExisting(idx *existingIndex, kind string, oldID int, extID, slug, title string) (existingItem, string, bool) {
+	if m.cfg.Options.OnExisting == onExistingDuplicate {
+		return existingItem{}, "", false
+	}
+
+	ids := m.state.Categories
+	if kind == kindProduct {
+		ids = m.state.Products
+	}
+	if newID, ok := ids[oldID]; ok {
+		if item, ok := idx.byID[newID]; ok {
+			return item, "state_file", true
+		}
+	}
+
+	return idx.match(m.matchBy, extID, slug, title)
+}
+
+// removeProduct 按 on_deleted 下架或删除新版商品
+func (m *Migrator) removeProduct(oldID int, name string, item existingItem) {
+	if m.cfg.Options.OnDeleted == "delete" {
+		resp, err := m.client.Delete(fmt.Sprintf("/products/%d", item.ID))
+		if err == nil && resp.StatusCode != 0 {
+			err = fmt.Errorf("%s", resp.Msg)
+		}
+		if err != nil {
+			log.Printf("  ✗ %s 删除失败 (ID:%d): %v", name, item.ID, err)
+			m.stats.Products.Failed++
+			return
+		}
+		delete(m.state.Products, oldID)
+		log.Printf("  🗑 %s 已删除: 老版已删除或停用 (ID:%d)", name, item.ID)
+		m.stats.Products.Deleted++
+		return
+	}
+
+	current := m.fetchDetail("/products", item.ID)
+	if current == nil {
+		current = item.Data
+	}
+	if active, ok := current["is_active"].(bool); ok && !active {
+		return
+	}
+
+	update := make(map[string]interface{}, len(current))
+	for k, v := range current {
+		update[k] = v
+	}
+	update["is_active"] = false
+
+	resp, err := m.client.Put(fmt.Sprintf("/products/%d", item.ID), update)
+	if err == nil && resp.StatusCode != 0 {
+		err = fmt.Errorf("%s", resp.Msg)
+	}
+	if err != nil {
+		log.Printf("  ✗ %s 下架失败 (ID:%d): %v", name, item.ID, err)
+		m.stats.Products.Failed++
+		return
+	}
+
+	log.Printf("  ⏸ %s 已下架: 老版已删除或停用 (ID:%d)", name, item.ID)
+	m.stats.Products.Deactivated++
+}
+
+// syncCards 导入上次同步后老版新增的卡密
+//
+// 商品未迁移时跳过的卡密不阻塞检查点，商品在之后的同步中创建时一并导入它的全部未售卡密
+func (m *Migrator) syncCards(productMap map[int]map[string]interface{}) error {
+	log.Println("\n=== 同步新增卡密 ===")
+
+	failedBefore := m.stats.Cards.Failed
+	created := m.importCreatedCards(productMap)
+
+	s := m.schema
+	query := s.Rebind(fmt.Sprintf("SELECT %s FROM %s WHERE %s > ? AND %s = 1 AND %s IS NULL ORDER BY %s",
+		s.Cols("carmis", "id", "goods_id", "carmi"), s.Table("carmis"), s.Col("carmis", "id"),
+		s.Col("carmis", "status"), s.Col("carmis", "deleted_at"), s.Col("carmis", "id")))
+	rows, err := m.db.Query(query, m.state.LastCardID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	secrets := make(map[int][]string)
+	var order []int
+	maxCardID := m.state.LastCardID
+	for rows.Next() {
+		var id, goodsID int
+		var carmi string
+		if err := rows.Scan(&id, &goodsID, &carmi); err != nil {
+			return err
+		}
+		if id > maxCardID {
+			maxCardID = id
+		}
+		if !m.filter.product(goodsID) || created[goodsID] {
+			continue
+		}
+		carmi, ok := m.transformCard(id, goodsID, carmi)
+		if !ok {
+			continue
+		}
+		if _, ok := secrets[goodsID]; !ok {
+			order = append(order, goodsID)
+		}
+		secrets[goodsID] = append(secrets[goodsID], carmi)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if len(order) == 0 && len(created) == 0 {
+		log.Println("没有新增卡密")
+	}
+
+	for _, oldProductID := range order {
+		info, ok := productMap[oldProductID]
+		if !ok {
+			log.Printf("  ⊘ 商品(老ID:%d) 未迁移，跳过 %d 条卡密，商品迁移后一并导入", oldProductID, len(secrets[oldProductID]))
+			m.stats.Cards.Skipped += len(secrets[oldProductID])
+			continue
+		}
+		m.importCards(oldProductID, toInt(info["new_id"]), secrets[oldProductID])
+	}
+
+	if m.stats.Cards.Failed == failedBefore {
+		m.state.LastCardID = maxCardID
+	}
+	return nil
+}
+
+// importCreatedCards 导入本轮新创建商品的全部未售卡密，返回这些商品的老版 ID
+func (m *Migrator) importCreatedCards(productMap map[int]map[string]interface{}) map[int]bool {
+	created := make(map[int]bool)
+	for oldProductID, info := range productMap {
+		if existing, _ := info["existing"].(bool); existing {
+			continue
+		}
+		created[oldProductID] = true
+
+		newProductID := toInt(info["new_id"])
+		secrets, _, err := m.productCards(oldProductID, 0)
+		if err != nil {
+			log.Printf("  ✗ 商品%d: 读取卡密失败: %v", newProductID, err)
+			m.stats.Cards.Failed++
+			continue
+		}
+		m.importCards(oldProductID, newProductID, secrets)
+	}
+	return created
+}
+
+// removedProductsFilter 检查点之后在老版删除或停用的商品
+func (m *Migrator) removedProductsFilter() (string, []interface{}) {
+	s := m.schema
+	where, args := m.rowFilter("goods")
+	removed := s.Col("goods", "deleted_at") + " IS NOT NULL"
+	if m.cfg.Options.OnlyActive {
+		removed += " OR " + s.Col("goods", "is_open") + " <> 1"
+	}
+	return where + " AND (" + removed + ")", args
+}
+
+// removedCardsFilter 已导入新版、检查点之后在老版售出或删除的卡密
+// 卡密表没有 updated_at/deleted_at 字段或还没有检查点时返回 false
+func (m *Migrator) removedCardsFilter() (string, []interface{}, bool) {
+	s := m.schema
+	checkpoint := m.state.Checkpoints["carmis"]
+	if checkpoint.IsZero() {
+		return "", nil, false
+	}
+
+	var conds []string
+	args := []interface{}{m.state.LastCardID}
+	for _, field := range []string{"updated_at", "deleted_at"} {
+		if s.Has("carmis", field) {
+			conds = append(conds, s.Col("carmis", field)+" >= ?")
+			args = append(args, s.TimeArg(checkpoint))
+		}
+	}
+	if len(conds) == 0 {
+		return "", nil, false
+	}
+
+	where := fmt.Sprintf("%s <= ? AND (%s <> 1 OR %s IS NOT NULL) AND (%s)",
+		s.Col("carmis", "id"), s.Col("carmis", "status"), s.Col("carmis", "deleted_at"),
+		strings.Join(conds, " OR "))
+	return where, args, true
+}
+
+// checkRemovals 统计本轮需要下架或删除的商品和卡密，超过 max_deletions 时中止，防止误删
+func (m *Migrator) checkRemovals() error {
+	limit := m.cfg.Options.MaxDeletions
+	if limit == 0 {
+		return nil
+	}
+	s := m.schema
+
+	total := 0
+	where, args := m.removedProductsFilter()
+	rows, err := m.db.Query(s.Rebind(fmt.Sprintf("SELECT %s FROM %s WHERE %s",
+		s.Col("goods", "id"), s.Table("goods"), where)), args...)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		if _, ok := m.state.Products[id]; ok && m.filter.product(id) {
+			total++
+		}
+	}
+	rows.Close()
+
+	if m.cfg.Options.MigrateCards {
+		if where, args, ok := m.removedCardsFilter(); ok {
+			query := s.Rebind(fmt.Sprintf("SELECT %s FROM %s WHERE %s",
+				s.Col("carmis", "goods_id"), s.Table("carmis"), where))
+			rows, err := m.db.Query(query, args...)
+			if err != nil {
+				return err
+			}
+			for rows.Next() {
+				var goodsID int
+				if err := rows.Scan(&goodsID); err != nil {
+					rows.Close()
+					return err
+				}
+				if m.filter.product(goodsID) {
+					total++
+				}
+			}
+			rows.Close()
+		}
+	}
+
+	if total > limit {
+		return fmt.Errorf("本轮检测到 %d 项需要下架或删除的商品和卡密，超过 max_deletions=%d，已中止；"+
+			"确认无误后请调大 options.max_deletions（0 不限制）再运行", total, limit)
+	}
+	return nil
+}
+
+// initCardCheckpoint 记录卡密表当前的最大修改时间，之后售出或删除的卡密才会同步删除
+func (m *Migrator) initCardCheckpoint() error {
+	if !m.state.Checkpoints["carmis"].IsZero() {
+		return nil
+	}
+	s := m.schema
+	var updated, deleted models.NullTime
+	query := fmt.Sprintf("SELECT MAX(%s), MAX(%s) FROM %s",
+		s.Col("carmis", "updated_at"), s.Col("carmis", "deleted_at"), s.Table("carmis"))
+	if err := m.db.QueryRow(query).Scan(&updated, &deleted); err != nil {
+		return err
+	}
+	m.observe("carmis", updated, deleted)
+	m.commitCheckpoint("carmis", true)
+	return nil
+}
+
+// syncRemovedCards 删除新版中已在老版售出或删除的卡密，避免同一张卡密被卖两次
+func (m *Migrator) syncRemovedCards() error {
+	if m.state.Checkpoints["carmis"].IsZero() {
+		return m.initCardCheckpoint()
+	}
+	where, args, ok := m.removedCardsFilter()
+	if !ok {
+		return nil
+	}
+
+	log.Println("\n=== 同步已售出/删除的卡密 ===")
+
+	s := m.schema
+	query := s.Rebind(fmt.Sprintf("SELECT %s FROM %s WHERE %s ORDER BY %s",
+		s.Cols("carmis", "id", "goods_id", "carmi", "updated_at", "deleted_at"),
+		s.Table("carmis"), where, s.Col("carmis", "id")))
+	rows, err := m.db.Query(query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	removed := make(map[int]map[string][]int)
+	var order []int
+	for rows.Next() {
+		var id, goodsID int
+		var carmi string
+		var updatedAt, deletedAt models.NullTime
+		if err := rows.Scan(&id, &goodsID, &carmi, &updatedAt, &deletedAt); err != nil {
+			return err
+		}
+		m.observe("carmis", updatedAt, deletedAt)
+		if !m.filter.product(goodsID) || m.state.RemovedCards[id] {
+			continue
+		}
+		// 导入时经过转换脚本的卡密，按转换后的内容在新版查找
+		if secret, keep, err := m.scriptCard(id, goodsID, carmi); err == nil {
+			if !keep {
+				continue
+			}
+			carmi = secret
+		}
+		if removed[goodsID] == nil {
+			removed[goodsID] = make(map[string][]int)
+			order = append(order, goodsID)
+		}
+		removed[goodsID][carmi] = append(removed[goodsID][carmi], id)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	failedBefore := m.stats.Cards.Failed
+	for _, oldProductID := range order {
+		newProductID, ok := m.state.Products[oldProductID]
+		if !ok {
+			continue
+		}
+		m.deleteCards(newProductID, removed[oldProductID])
+	}
+
+	m.commitCheckpoint("carmis", m.stats.Cards.Failed == failedBefore)
+	return nil
+}
+
+// deleteCards 在新版商品的卡密中查找并删除指定卡密，新版已售出的卡密不处理
+//
+// 先读取全部分页再删除，边翻页边删除会使后面的卡密前移到已读过的页而被漏掉。
+// 新版中找不到的卡密计入失败，本轮不推进检查点
+func (m *Migrator) deleteCards(productID int, secrets map[string][]int) {
+	sold := make(map[string]bool, len(m.cfg.Options.SoldCardStatuses))
+	for _, status := range m.cfg.Options.SoldCardStatuses {
+		sold[status] = true
+	}
+
+	type match struct {
+		id     int
+		secret string
+	}
+	var matches []match
+	found := make(map[string]int)
+	seen := make(map[int]bool)
+	for page := 1; ; page++ {
+		resp, err := m.client.Get(fmt.Sprintf("/card-secrets?product_id=%d&page=%d&page_size=100", productID, page))
+		if err != nil || resp.StatusCode != 0 {
+			log.Printf("  ✗ 商品%d: 获取卡密列表失败", productID)
+			m.stats.Cards.Failed += len(secrets)
+			return
+		}
+
+		// 空页或没有新卡密（接口不支持分页）时结束
+		fresh := false
+		for _, entry := range extractDataList(resp.Data) {
+			card, ok := entry.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			id := toInt(card["id"])
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+			fresh = true
+
+			secret, _ := card["secret"].(string)
+			// 新版有重复卡密时，最多处理与老版删除数量相同的张数
+			if found[secret] >= len(secrets[secret]) {
+				continue
+			}
+			found[secret]++
+			if sold[fmt.Sprint(card["status"])] {
+				continue
+			}
+			matches = append(matches, match{id: id, secret: secret})
+		}
+		if !fresh {
+			break
+		}
+	}
+
+	failedBefore := m.stats.Cards.Failed
+	failed := make(map[string]bool)
+	for _, c := range matches {
+		resp, err := m.client.Delete(fmt.Sprintf("/card-secrets/%d", c.id))
+		if err == nil && resp.StatusCode != 0 {
+			err = fmt.Errorf("%s", resp.Msg)
+		}
+		if err != nil {
+			log.Printf("  ✗ 商品%d: 删除卡密 %d 失败: %v", productID, c.id, err)
+			m.stats.Cards.Failed++
+			failed[c.secret] = true
+			continue
+		}
+		m.stats.Cards.Removed++
+	}
+
+	// 已处理的老版卡密记录到状态文件，之后重复查到时不再查找
+	for secret, cardIDs := range secrets {
+		n := found[secret]
+		if !failed[secret] {
+			for _, id := range cardIDs[:n] {
+				m.state.RemovedCards[id] = true
+			}
+		}
+		if n < len(cardIDs) {
+			log.Printf("  ✗ 商品%d: 新版中没有找到老版卡密 (老ID:%v)", productID, cardIDs[n:])
+			m.stats.Cards.Failed += len(cardIDs) - n
+		}
+	}
+	if m.stats.Cards.Failed == failedBefore {
+		log.Printf("  ✓ 商品%d: 已删除老版售出/删除的卡密", productID)
+	}
+}
@@ -0,0 +1,69 @@
+package migrator
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/luoyanglang/dujiao-migrate/internal/script"
+)
+
+// runScript 调用转换脚本的钩子，返回修改后的请求和是否跳过；脚本未定义该钩子时原样返回
+func (m *Migrator) runScript(hook string, row, payload map[string]interface{}) (map[string]interface{}, bool, error) {
+	if !m.script.Has(hook) {
+		return payload, false, nil
+	}
+	if row == nil {
+		row = map[string]interface{}{}
+	}
+	updated, skip, err := m.script.Call(hook, row, payload)
+	if err != nil || skip {
+		return nil, skip, err
+	}
+
+	// 脚本修改的价格保持十进制
+	for k, v := range updated {
+		if _, ok := payload[k].(priceAmount); !ok {
+			continue
+		}
+		if _, ok := v.(priceAmount); ok {
+			continue
+		}
+		d, err := toDecimal(v)
+		if err != nil {
+			return nil, false, fmt.Errorf("%s: %s %w", hook, k, err)
+		}
+		updated[k] = priceAmount{d}
+	}
+	return updated, false, nil
+}
+
+// scriptCard 按转换脚本处理一条卡密，返回处理后的卡密和是否保留
+func (m *Migrator) scriptCard(id, goodsID int, carmi string) (string, bool, error) {
+	if !m.script.Has(script.HookCard) {
+		return carmi, true, nil
+	}
+	row := map[string]interface{}{"id": id, "goods_id": goodsID, "carmi": carmi}
+	payload, skip, err := m.script.Call(script.HookCard, row, map[string]interface{}{"secret": carmi})
+	if err != nil || skip {
+		return carmi, false, err
+	}
+	secret, ok := payload["secret"].(string)
+	if !ok || secret == "" {
+		return carmi, false, fmt.Errorf("%s 返回的 secret 无效", script.HookCard)
+	}
+	return secret, true, nil
+}
+
+// transformCard 导入卡密前调用转换脚本，失败或跳过时计入统计
+func (m *Migrator) transformCard(id, goodsID int, carmi string) (string, bool) {
+	secret, keep, err := m.scriptCard(id, goodsID, carmi)
+	if err != nil {
+		log.Printf("  ✗ 卡密(老ID:%d) 转换脚本失败: %v", id, err)
+		m.stats.Cards.Failed++
+		return "", false
+	}
+	if !keep {
+		m.stats.Cards.Skipped++
+	}
+	return secret, keep
+}
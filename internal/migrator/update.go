@@ -0,0 +1,162 @@
+package migrator
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// 已存在数据的处理方式
+const (
+	onExistingSkip      = "skip"      // 跳过
+	onExistingUpdate    = "update"    // 同步有变化的字段
+	onExistingDuplicate = "duplicate" // 不检查，重新创建
+)
+
+// 更新模式下比较的字段
+var (
+	categoryDiffFields = []string{"name"}
+	productDiffFields  = []string{"price_amount", "title", "description", "content", "images", "manual_stock_total"}
+)
+
+// productCompareFields 商品比较的字段，api_hook 迁移为回调字段且新版接口返回该字段时也比较回调字段
+func (m *Migrator) productCompareFields() []string {
+	if m.cfg.Fulfillment.APIHook != "webhook" || m.hookMissing {
+		return productDiffFields
+	}
+	return append(productDiffFields[:len(productDiffFields):len(productDiffFields)], m.cfg.Fulfillment.WebhookField)
+}
+
+// verifyProduct 创建或更新商品后读取新版商品，核对价格和 api_hook 是否按迁移的值保存
+func (m *Migrator) verifyProduct(name string, id int, payload map[string]interface{}) {
+	_, hasHook := payload[m.cfg.Fulfillment.WebhookField]
+	hasHook = hasHook && m.cfg.Fulfillment.APIHook == "webhook"
+	if !m.cfg.Options.VerifyPrices && !hasHook {
+		return
+	}
+	detail := m.fetchDetail("/products", id)
+	if detail == nil {
+		return
+	}
+	if m.cfg.Options.VerifyPrices {
+		m.checkPrice(name, id, payload, detail)
+	}
+	if hasHook {
+		m.checkHook(name, id, payload, detail)
+	}
+}
+
+// syncExisting 比较老版数据和新版已存在的数据，有变化时调用更新接口，返回有变化的字段
+//
+// 更新请求以新版现有数据为基础，只覆盖有变化的比较字段，上下架、分类、排序等在新版修改过的字段保持不变；
+// 多语言字段只覆盖老版有内容的语言，新版手动补充的翻译会保留
+func (m *Migrator) syncExisting(endpoint string, item existingItem, payload map[string]interface{}, fields []string) ([]string, error) {
+	current := m.fetchDetail(endpoint, item.ID)
+	if current == nil {
+		current = item.Data
+	}
+
+	var changed []string
+	update := make(map[string]interface{}, len(current))
+	for k, v := range current {
+		update[k] = v
+	}
+	for _, field := range fields {
+		value, ok := payload[field]
+		if !ok {
+			continue
+		}
+		if localized, ok := value.(map[string]string); ok {
+			value = mergeLocalized(current[field], localized)
+		}
+		if !valuesEqual(value, current[field]) {
+			update[field] = value
+			changed = append(changed, field)
+		}
+	}
+	if len(changed) == 0 {
+		return nil, nil
+	}
+
+	resp, err := m.client.Put(fmt.Sprintf("%s/%d", endpoint, item.ID), update)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != 0 {
+		return nil, fmt.Errorf("%s", resp.Msg)
+	}
+	return changed, nil
+}
+
+// mergeLocalized 以新版现有的多语言内容为基础，覆盖老版有内容的语言
+func mergeLocalized(current interface{}, localized map[string]string) map[string]string {
+	merged := make(map[string]string, len(localized))
+	if cur, ok := current.(map[string]interface{}); ok {
+		for lang, v := range cur {
+			if s, ok := v.(string); ok {
+				merged[lang] = s
+			}
+		}
+	}
+	for lang, s := range localized {
+		if s != "" || merged[lang] == "" {
+			merged[lang] = s
+		}
+	}
+	return merged
+}
+
+// valuesEqual 比较请求值和接口返回值，忽略数字格式（12.5 与 "12.50"）和空值差异
+func valuesEqual(a, b interface{}) bool {
+	return reflect.DeepEqual(normalizeValue(a), normalizeValue(b))
+}
+
+// normalizeValue 经 JSON 转换为通用结构后规范化：数字字符串转为数字，空字符串、空数组、空对象视为 nil
+func normalizeValue(v interface{}) interface{} {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return v
+	}
+	var generic interface{}
+	if err := json.Unmarshal(data, &generic); err != nil {
+		return v
+	}
+	return normalizeGeneric(generic)
+}
+
+func normalizeGeneric(v interface{}) interface{} {
+	switch t := v.(type) {
+	case string:
+		s := strings.TrimSpace(t)
+		if s == "" {
+			return nil
+		}
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			return f
+		}
+		return s
+	case []interface{}:
+		if len(t) == 0 {
+			return nil
+		}
+		out := make([]interface{}, len(t))
+		for i, e := range t {
+			out[i] = normalizeGeneric(e)
+		}
+		return out
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(t))
+		for k, e := range t {
+			if n := normalizeGeneric(e); n != nil {
+				out[k] = n
+			}
+		}
+		if len(out) == 0 {
+			return nil
+		}
+		return out
+	}
+	return v
+}
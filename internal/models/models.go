@@ -1,6 +1,12 @@
 package models
 
-import "database/sql"
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
 
 // Category 分类
 type Category struct {
@@ -8,23 +14,67 @@ type Category struct {
 	Name   string
 	Ord    int
 	IsOpen int
+
+	CreatedAt NullTime
+	UpdatedAt NullTime
+	DeletedAt NullTime
 }
 
 // Product 商品
 type Product struct {
-	ID            int
-	GroupID       int
-	Name          string
-	Description   sql.NullString
-	Keywords      sql.NullString
-	Picture       sql.NullString
-	ActualPrice   float64
-	InStock       int
-	Ord           int
-	Type          int
-	Content       sql.NullString
-	OtherIpuCnf   sql.NullString
-	IsOpen        int
+	ID          int
+	GroupID     int
+	Name        string
+	Description sql.NullString
+	Keywords    sql.NullString
+	Picture     sql.NullString
+	ActualPrice decimal.Decimal // 按十进制读取，不经过 float64
+	InStock     int
+	Ord         int
+	Type        int
+	Content     sql.NullString
+	OtherIpuCnf sql.NullString
+	APIHook     sql.NullString // 支付后回调地址，早期版本没有此字段
+	IsOpen      int
+
+	CreatedAt NullTime
+	UpdatedAt NullTime
+	DeletedAt NullTime
+}
+
+// timeLayouts 以字符串返回的时间格式（SQLite 的 TEXT 列和 MAX() 结果、未开启 parseTime 的 MySQL）
+var timeLayouts = []string{
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02 15:04:05.999999999-07:00",
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02",
+}
+
+// NullTime 可为 NULL 的时间，驱动以字符串返回时按 UTC 解析，与 MySQL、SQLite 驱动解析时间列的方式一致
+type NullTime struct {
+	sql.NullTime
+}
+
+// Scan 实现 sql.Scanner
+func (n *NullTime) Scan(value interface{}) error {
+	var s string
+	switch v := value.(type) {
+	case string:
+		s = v
+	case []byte:
+		s = string(v)
+	default:
+		return n.NullTime.Scan(value)
+	}
+
+	for _, layout := range timeLayouts {
+		if t, err := time.ParseInLocation(layout, s, time.UTC); err == nil {
+			n.Time, n.Valid = t, true
+			return nil
+		}
+	}
+	return fmt.Errorf("无法解析时间 %q", s)
 }
 
 // Card 卡密
@@ -42,19 +92,30 @@ type Stats struct {
 // CategoryStats 分类统计
 type CategoryStats struct {
 	Success int
+	Updated int
+	Mapped  int // 合并到其他分类或映射到新版已有分类
+	Dropped int // 按映射规则不迁移
 	Skipped int
 	Failed  int
 }
 
 // ProductStats 商品统计
 type ProductStats struct {
-	Success int
-	Skipped int
-	Failed  int
+	Success     int
+	Updated     int
+	Deactivated int
+	Deleted     int
+	Skipped     int
+	Failed      int
+
+	PriceMismatch int // 新版保存的价格与迁移的价格不一致
+	HookSkipped   int // api_hook 未迁移
 }
 
 // CardStats 卡密统计
 type CardStats struct {
 	Success int
+	Removed int
+	Skipped int // 转换脚本跳过
 	Failed  int
 }
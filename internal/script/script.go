@@ -0,0 +1,199 @@
+// Package script 用户转换脚本（JavaScript），对每一行数据修改或跳过迁移请求
+package script
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/dop251/goja"
+)
+
+// 支持的钩子
+const (
+	HookCategory = "transformCategory"
+	HookProduct  = "transformProduct"
+	HookCard     = "transformCard"
+)
+
+// timeout 单次调用的最长执行时间，防止脚本死循环卡住迁移
+const timeout = 5 * time.Second
+
+// Engine 脚本运行环境，不能并发使用
+type Engine struct {
+	vm        *goja.Runtime
+	hooks     map[string]goja.Callable
+	parse     goja.Callable // JSON.parse
+	stringify goja.Callable // JSON.stringify
+}
+
+// Load 加载脚本文件，脚本中至少定义一个钩子函数
+//
+//	function transformProduct(row, payload) {
+//	  payload.description["zh-CN"] = payload.description["zh-CN"].replace("水印", "")
+//	  if (row.gd_name.indexOf("测试") >= 0) return false  // 跳过
+//	}
+func Load(path string) (*Engine, error) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取转换脚本失败: %w", err)
+	}
+
+	vm := goja.New()
+	console := vm.NewObject()
+	console.Set("log", func(call goja.FunctionCall) goja.Value {
+		args := make([]string, len(call.Arguments))
+		for i, arg := range call.Arguments {
+			args[i] = arg.String()
+		}
+		log.Printf("  [脚本] %s", strings.Join(args, " "))
+		return goja.Undefined()
+	})
+	vm.Set("console", console)
+
+	if _, err := runWithTimeout(vm, func() (goja.Value, error) {
+		return vm.RunScript(path, string(src))
+	}); err != nil {
+		return nil, fmt.Errorf("执行转换脚本失败: %w", err)
+	}
+
+	e := &Engine{vm: vm, hooks: make(map[string]goja.Callable)}
+	for _, name := range []string{HookCategory, HookProduct, HookCard} {
+		if fn, ok := goja.AssertFunction(vm.Get(name)); ok {
+			e.hooks[name] = fn
+		}
+	}
+	if len(e.hooks) == 0 {
+		return nil, fmt.Errorf("转换脚本 %s 没有定义 %s、%s 或 %s", path, HookCategory, HookProduct, HookCard)
+	}
+
+	jsonObj := vm.Get("JSON").ToObject(vm)
+	e.parse, _ = goja.AssertFunction(jsonObj.Get("parse"))
+	e.stringify, _ = goja.AssertFunction(jsonObj.Get("stringify"))
+	return e, nil
+}
+
+// Has 脚本是否定义了钩子，e 为 nil 时返回 false
+func (e *Engine) Has(hook string) bool {
+	if e == nil {
+		return false
+	}
+	_, ok := e.hooks[hook]
+	return ok
+}
+
+// Call 调用钩子 hook(row, payload)，返回修改后的请求
+//
+// 钩子可以直接修改 payload 或返回新的对象；返回 false 或 null 时跳过该行（skip 为 true）。
+// 未修改的字段保留原值和原类型
+func (e *Engine) Call(hook string, row, payload map[string]interface{}) (map[string]interface{}, bool, error) {
+	fn, ok := e.hooks[hook]
+	if !ok {
+		return payload, false, nil
+	}
+
+	jsRow, err := e.toJS(row)
+	if err != nil {
+		return nil, false, err
+	}
+	jsPayload, err := e.toJS(payload)
+	if err != nil {
+		return nil, false, err
+	}
+
+	result, err := runWithTimeout(e.vm, func() (goja.Value, error) {
+		return fn(goja.Undefined(), jsRow, jsPayload)
+	})
+	if err != nil {
+		return nil, false, fmt.Errorf("%s: %w", hook, err)
+	}
+
+	switch {
+	case goja.IsUndefined(result):
+		result = jsPayload
+	case goja.IsNull(result):
+		return nil, true, nil
+	case result.ExportType() != nil && result.ExportType().Kind() == reflect.Bool:
+		if !result.ToBoolean() {
+			return nil, true, nil
+		}
+		result = jsPayload
+	}
+
+	updated, err := e.fromJS(result)
+	if err != nil {
+		return nil, false, fmt.Errorf("%s 返回值无效: %w", hook, err)
+	}
+	return restoreTypes(payload, updated), false, nil
+}
+
+// toJS 通过 JSON 转换为普通的 JS 对象，脚本中可以正常增删属性
+func (e *Engine) toJS(v interface{}) (goja.Value, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return e.parse(goja.Undefined(), e.vm.ToValue(string(data)))
+}
+
+func (e *Engine) fromJS(v goja.Value) (map[string]interface{}, error) {
+	str, err := e.stringify(goja.Undefined(), v)
+	if err != nil {
+		return nil, err
+	}
+	// 数字保留为 json.Number，不经过 float64
+	dec := json.NewDecoder(strings.NewReader(str.String()))
+	dec.UseNumber()
+	var result map[string]interface{}
+	if err := dec.Decode(&result); err != nil {
+		return nil, fmt.Errorf("应返回对象")
+	}
+	return result, nil
+}
+
+// runWithTimeout 执行脚本，超时后中断
+func runWithTimeout(vm *goja.Runtime, run func() (goja.Value, error)) (goja.Value, error) {
+	timer := time.AfterFunc(timeout, func() {
+		vm.Interrupt(fmt.Sprintf("执行超过 %s", timeout))
+	})
+	defer timer.Stop()
+
+	v, err := run()
+	vm.ClearInterrupt()
+	return v, err
+}
+
+// restoreTypes 经过 JSON 转换后值没有变化的字段保留原值（如 int、map[string]string），
+// 变化的多语言字段转换回 map[string]string
+func restoreTypes(orig, updated map[string]interface{}) map[string]interface{} {
+	for k, v := range updated {
+		old, ok := orig[k]
+		if !ok {
+			continue
+		}
+		if sameJSON(old, v) {
+			updated[k] = old
+			continue
+		}
+		if _, localized := old.(map[string]string); localized {
+			if m, ok := v.(map[string]interface{}); ok {
+				converted := make(map[string]string, len(m))
+				for lang, text := range m {
+					converted[lang] = fmt.Sprint(text)
+				}
+				updated[k] = converted
+			}
+		}
+	}
+	return updated
+}
+
+func sameJSON(a, b interface{}) bool {
+	x, err1 := json.Marshal(a)
+	y, err2 := json.Marshal(b)
+	return err1 == nil && err2 == nil && string(x) == string(y)
+}
@@ -0,0 +1,331 @@
+// Package translate 中文到英文的翻译提供方
+package translate
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/luoyanglang/dujiao-migrate/internal/config"
+)
+
+// New 根据配置创建翻译器，HTTP 翻译启用缓存时返回 *Cached
+// 本地术语表本身没有成本，不做缓存，修改术语表后立即生效
+func New(cfg config.I18nConfig) (Translator, error) {
+	switch cfg.EnUS {
+	case "", "none":
+		return Noop{}, nil
+	case "glossary":
+		return NewGlossary(cfg.GlossaryFile)
+	case "http":
+		h := NewHTTP(cfg.TranslateEndpoint, cfg.TranslateAPIKey)
+		if cfg.TranslateCache == "" {
+			return h, nil
+		}
+		return NewCached(h, "http:"+cfg.TranslateEndpoint, cfg.TranslateCache)
+	default:
+		return nil, fmt.Errorf("不支持的翻译方式: %s", cfg.EnUS)
+	}
+}
+
+// Translator 翻译接口，将 zh-CN 文本翻译为 en-US
+// 无法翻译时返回空字符串
+type Translator interface {
+	Translate(text string) (string, error)
+}
+
+// Noop 不翻译，en-US 保持为空
+type Noop struct{}
+
+// Translate 始终返回空字符串
+func (Noop) Translate(string) (string, error) {
+	return "", nil
+}
+
+// --- 本地术语表 ---
+
+// punctuation 中文标点转换为英文标点
+var punctuation = strings.NewReplacer(
+	"，", ", ", "。", ". ", "、", ", ", "：", ": ", "；", "; ",
+	"！", "! ", "？", "? ", "（", " (", "）", ") ", "【", " [", "】", "] ",
+	"“", "\"", "”", "\"", "《", "", "》", "",
+)
+
+// spacing 整理替换后括号和标点两侧多余的空格
+var spacing = strings.NewReplacer(
+	"( ", "(", " )", ")", "[ ", "[", " ]", "]",
+	" ,", ",", " .", ".", " :", ":", " ;", ";", " !", "!", " ?", "?",
+)
+
+// Glossary 基于 CSV 术语表的翻译（每行: 中文,英文）
+// 整句命中时直接返回；否则按最长词优先替换术语，仍残留中文时视为无法翻译
+type Glossary struct {
+	exact map[string]string
+	terms []string // 按长度降序
+}
+
+// NewGlossary 加载 CSV 术语表，第一行为 zh,en 表头时自动跳过
+func NewGlossary(path string) (*Glossary, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取术语表失败: %w", err)
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	reader.Comment = '#'
+	reader.TrimLeadingSpace = true
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("解析术语表失败: %w", err)
+	}
+
+	g := &Glossary{exact: make(map[string]string)}
+	for i, record := range records {
+		if len(record) < 2 {
+			continue
+		}
+		zh := strings.TrimSpace(record[0])
+		en := strings.TrimSpace(record[1])
+		if i == 0 && strings.EqualFold(zh, "zh") {
+			continue
+		}
+		if zh == "" || en == "" {
+			continue
+		}
+		g.exact[zh] = en
+		g.terms = append(g.terms, zh)
+	}
+
+	sort.SliceStable(g.terms, func(i, j int) bool {
+		return len(g.terms[i]) > len(g.terms[j])
+	})
+	return g, nil
+}
+
+// Translate 使用术语表翻译
+func (g *Glossary) Translate(text string) (string, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return "", nil
+	}
+	if en, ok := g.exact[text]; ok {
+		return en, nil
+	}
+
+	result := text
+	for _, zh := range g.terms {
+		if strings.Contains(result, zh) {
+			result = strings.ReplaceAll(result, zh, " "+g.exact[zh]+" ")
+		}
+	}
+	if containsHan(result) {
+		return "", nil
+	}
+	result = punctuation.Replace(result)
+	return spacing.Replace(strings.Join(strings.Fields(result), " ")), nil
+}
+
+// --- HTTP 翻译接口 ---
+
+// HTTP 通用 HTTP 翻译接口
+//
+// 请求: POST {endpoint}，JSON {"text": "...", "source": "zh-CN", "target": "en-US"}
+// 响应: JSON {"text": "..."}（也兼容 {"data": {"text": "..."}}）
+type HTTP struct {
+	endpoint   string
+	apiKey     string
+	httpClient *http.Client
+}
+
+// NewHTTP 创建 HTTP 翻译器，apiKey 非空时以 Bearer Token 发送
+func NewHTTP(endpoint, apiKey string) *HTTP {
+	return &HTTP{
+		endpoint: endpoint,
+		apiKey:   apiKey,
+		httpClient: &http.Client{
+			Timeout: 30 * time.Second,
+		},
+	}
+}
+
+// Translate 调用 HTTP 接口翻译
+func (h *HTTP) Translate(text string) (string, error) {
+	if strings.TrimSpace(text) == "" {
+		return "", nil
+	}
+
+	payload, err := json.Marshal(map[string]string{
+		"text":   text,
+		"source": "zh-CN",
+		"target": "en-US",
+	})
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequest("POST", h.endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if h.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+h.apiKey)
+	}
+
+	resp, err := h.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("翻译请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("翻译接口返回 HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var result struct {
+		Text string `json:"text"`
+		Data struct {
+			Text string `json:"text"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return "", fmt.Errorf("解析翻译响应失败: %w", err)
+	}
+	if result.Text != "" {
+		return result.Text, nil
+	}
+	return result.Data.Text, nil
+}
+
+// --- 磁盘缓存 ---
+
+// Cached 为翻译器增加磁盘缓存，重复运行时不再重复翻译
+type Cached struct {
+	inner    Translator
+	provider string
+	path     string
+
+	mu      sync.Mutex
+	entries map[string]map[string]string // 提供方 -> 原文 -> 译文
+	dirty   int
+}
+
+// saveEvery 新增多少条缓存后写盘一次
+const saveEvery = 20
+
+// NewCached 创建带缓存的翻译器，provider 用于区分不同翻译来源的缓存
+func NewCached(inner Translator, provider, path string) (*Cached, error) {
+	c := &Cached{
+		inner:    inner,
+		provider: provider,
+		path:     path,
+		entries:  make(map[string]map[string]string),
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("读取翻译缓存失败: %w", err)
+	}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &c.entries); err != nil {
+			return nil, fmt.Errorf("解析翻译缓存失败: %w", err)
+		}
+	}
+	if c.entries[provider] == nil {
+		c.entries[provider] = make(map[string]string)
+	}
+
+	return c, nil
+}
+
+// Translate 优先使用缓存，未命中时调用内部翻译器
+func (c *Cached) Translate(text string) (string, error) {
+	c.mu.Lock()
+	if en := c.entries[c.provider][text]; en != "" {
+		c.mu.Unlock()
+		return en, nil
+	}
+	c.mu.Unlock()
+
+	en, err := c.inner.Translate(text)
+	if err != nil {
+		return "", err
+	}
+	// 空结果（无法翻译）从不缓存，补充术语或翻译接口恢复后重新运行即可翻译
+	if en == "" {
+		return "", nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[c.provider][text] = en
+	c.dirty++
+	if c.dirty >= saveEvery {
+		// 写盘失败时保留未保存条目，由 Save 再次尝试并报告错误
+		c.save()
+	}
+	return en, nil
+}
+
+// Save 将缓存写入磁盘
+func (c *Cached) Save() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.save()
+}
+
+func (c *Cached) save() error {
+	if c.dirty == 0 {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(c.entries, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if dir := filepath.Dir(c.path); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("创建翻译缓存目录失败: %w", err)
+		}
+	}
+
+	// 先写临时文件再重命名，避免中断时损坏缓存
+	tmp := c.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("写入翻译缓存失败: %w", err)
+	}
+	if err := os.Rename(tmp, c.path); err != nil {
+		return fmt.Errorf("写入翻译缓存失败: %w", err)
+	}
+
+	c.dirty = 0
+	return nil
+}
+
+// containsHan 检测是否包含汉字
+func containsHan(s string) bool {
+	for _, r := range s {
+		if unicode.Is(unicode.Han, r) {
+			return true
+		}
+	}
+	return false
+}
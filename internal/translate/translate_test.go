@@ -0,0 +1,201 @@
+package translate
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/luoyanglang/dujiao-migrate/internal/config"
+)
+
+// countingTranslator 记录调用次数，返回预设的译文
+type countingTranslator struct {
+	results map[string]string
+	calls   int
+}
+
+func (c *countingTranslator) Translate(text string) (string, error) {
+	c.calls++
+	return c.results[text], nil
+}
+
+func TestHTTPTranslate(t *testing.T) {
+	tests := []struct {
+		name     string
+		response string
+		want     string
+	}{
+		{"text", `{"text": "Game Cards"}`, "Game Cards"},
+		{"data.text", `{"data": {"text": "Game Cards"}}`, "Game Cards"},
+		{"empty", `{}`, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != http.MethodPost {
+					t.Errorf("method = %s, want POST", r.Method)
+				}
+				if got := r.Header.Get("Authorization"); got != "Bearer secret" {
+					t.Errorf("Authorization = %q", got)
+				}
+				var req map[string]string
+				if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+					t.Fatal(err)
+				}
+				if req["text"] != "游戏点卡" || req["source"] != "zh-CN" || req["target"] != "en-US" {
+					t.Errorf("request = %v", req)
+				}
+				w.Write([]byte(tt.response))
+			}))
+			defer srv.Close()
+
+			got, err := NewHTTP(srv.URL, "secret").Translate("游戏点卡")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("Translate() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHTTPTranslateError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "quota exceeded", http.StatusTooManyRequests)
+	}))
+	defer srv.Close()
+
+	if _, err := NewHTTP(srv.URL, "").Translate("游戏点卡"); err == nil {
+		t.Fatal("expected error for HTTP 429")
+	}
+}
+
+func TestHTTPTranslateBlank(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("blank text should not be sent")
+	}))
+	defer srv.Close()
+
+	if got, err := NewHTTP(srv.URL, "").Translate("  "); err != nil || got != "" {
+		t.Fatalf("Translate() = %q, %v", got, err)
+	}
+}
+
+func TestGlossaryTranslate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "glossary.csv")
+	csv := "zh,en\n游戏点卡,Game Cards\n充值卡,Top-up Card\n月卡,Monthly Card\n"
+	if err := os.WriteFile(path, []byte(csv), 0644); err != nil {
+		t.Fatal(err)
+	}
+	g, err := NewGlossary(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		text, want string
+	}{
+		{"游戏点卡", "Game Cards"},
+		{"Steam充值卡（月卡）", "Steam Top-up Card (Monthly Card)"},
+		{"未知商品", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		got, err := g.Translate(tt.text)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("Translate(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestNew(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"text": "ok"}`))
+	}))
+	defer srv.Close()
+
+	tr, err := New(config.I18nConfig{})
+	if _, ok := tr.(Noop); err != nil || !ok {
+		t.Errorf("New(none) = %T, %v", tr, err)
+	}
+	tr, err = New(config.I18nConfig{EnUS: "http", TranslateEndpoint: srv.URL})
+	if _, ok := tr.(*HTTP); err != nil || !ok {
+		t.Errorf("New(http) = %T, %v", tr, err)
+	}
+	cache := filepath.Join(t.TempDir(), "cache.json")
+	tr, err = New(config.I18nConfig{EnUS: "http", TranslateEndpoint: srv.URL, TranslateCache: cache})
+	if _, ok := tr.(*Cached); err != nil || !ok {
+		t.Errorf("New(http+cache) = %T, %v", tr, err)
+	}
+	if _, err := New(config.I18nConfig{EnUS: "unknown"}); err == nil {
+		t.Error("New(unknown) should fail")
+	}
+}
+
+func TestCachedRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache", "translate.json")
+	inner := &countingTranslator{results: map[string]string{"游戏点卡": "Game Cards"}}
+
+	c, err := NewCached(inner, "http:a", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if got, _ := c.Translate("游戏点卡"); got != "Game Cards" {
+			t.Fatalf("Translate() = %q", got)
+		}
+	}
+	if inner.calls != 1 {
+		t.Errorf("inner called %d times, want 1", inner.calls)
+	}
+	if err := c.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 重新加载后命中缓存，不调用内部翻译器
+	inner = &countingTranslator{}
+	c, err = NewCached(inner, "http:a", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := c.Translate("游戏点卡"); got != "Game Cards" || inner.calls != 0 {
+		t.Errorf("after reload: Translate() = %q, inner calls = %d", got, inner.calls)
+	}
+
+	// 不同提供方的缓存互不影响
+	c, err = NewCached(inner, "http:b", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := c.Translate("游戏点卡"); got != "" || inner.calls != 1 {
+		t.Errorf("other provider: Translate() = %q, inner calls = %d", got, inner.calls)
+	}
+}
+
+func TestCachedSkipsEmpty(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "translate.json")
+	inner := &countingTranslator{}
+
+	c, err := NewCached(inner, "http:a", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Translate("未知商品")
+	c.Translate("未知商品")
+	if inner.calls != 2 {
+		t.Errorf("inner called %d times, want 2 (empty results must not be cached)", inner.calls)
+	}
+	if err := c.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("cache file written for empty results: %v", err)
+	}
+}
@@ -0,0 +1,128 @@
+package utils
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/mozillazg/go-pinyin"
+)
+
+// builtinPhrases 常见多音字词组的读音，go-pinyin 按单字取默认读音，这些词会读错
+var builtinPhrases = map[string]string{
+	"重庆": "chong qing", "重新": "chong xin", "重复": "chong fu", "重置": "chong zhi",
+	"重启": "chong qi", "重生": "chong sheng", "重装": "chong zhuang", "重制": "chong zhi",
+	"银行": "yin hang", "行业": "hang ye", "行情": "hang qing", "行家": "hang jia",
+	"音乐": "yin yue", "乐队": "yue dui", "乐器": "yue qi", "快乐": "kuai le",
+	"长城": "chang cheng", "长度": "chang du", "长期": "chang qi", "成长": "cheng zhang",
+	"校长": "xiao zhang", "会计": "kuai ji", "朝阳": "zhao yang", "朝鲜": "chao xian",
+	"厦门": "xia men", "大厦": "da sha", "传记": "zhuan ji", "调整": "tiao zheng",
+	"调查": "diao cha", "角色": "jue se", "主角": "zhu jue", "给予": "ji yu",
+	"处理": "chu li", "处方": "chu fang", "差价": "cha jia", "出差": "chu chai",
+	"还原": "huan yuan", "还款": "huan kuan", "背包": "bei bao", "头发": "tou fa",
+	"理发": "li fa", "爱好": "ai hao", "首都": "shou du", "都市": "du shi",
+	"中奖": "zhong jiang", "反省": "fan xing", "省钱": "sheng qian", "数据": "shu ju",
+	"数码": "shu ma", "单机": "dan ji", "单于": "chan yu", "降落": "jiang luo",
+	"投降": "tou xiang", "觉得": "jue de", "睡觉": "shui jiao", "了解": "liao jie",
+	"空调": "kong tiao", "强大": "qiang da", "勉强": "mian qiang", "仔细": "zi xi",
+	"着急": "zhao ji", "着陆": "zhuo lu", "模样": "mu yang", "模型": "mo xing",
+	"薄荷": "bo he", "曝光": "bao guang", "解说": "jie shuo", "游说": "you shui",
+}
+
+// PinyinDict 词组读音词典（词语 -> 各字拼音）
+type PinyinDict map[string][]string
+
+// LoadPinyinDict 加载自定义拼音词典，优先级高于内置多音字词组
+//
+// 每行一个词语，后接各字拼音，以空格、Tab 或逗号分隔，# 开头为注释:
+//
+//	重庆 chong qing
+//	长安,chang,an
+func LoadPinyinDict(path string) (PinyinDict, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取拼音词典失败: %w", err)
+	}
+	defer file.Close()
+
+	dict := make(PinyinDict)
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.FieldsFunc(text, func(r rune) bool {
+			return r == ' ' || r == '\t' || r == ',' || r == '，'
+		})
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("拼音词典第 %d 行格式错误，应为: 词语 拼音 拼音...", line)
+		}
+		syllables := make([]string, 0, len(fields)-1)
+		for _, s := range fields[1:] {
+			syllables = append(syllables, strings.ToLower(s))
+		}
+		dict[fields[0]] = syllables
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("读取拼音词典失败: %w", err)
+	}
+
+	return dict, nil
+}
+
+// phraseTable 按最大正向匹配查找词组读音
+type phraseTable struct {
+	dict   PinyinDict
+	maxLen int // 最长词语的字符数
+}
+
+var defaultPhrases = newPhraseTable(nil)
+
+// newPhraseTable 合并内置词组和自定义词典，自定义词典优先
+func newPhraseTable(user PinyinDict) *phraseTable {
+	t := &phraseTable{dict: make(PinyinDict, len(builtinPhrases)+len(user))}
+	for word, syllables := range builtinPhrases {
+		t.dict[word] = strings.Fields(syllables)
+	}
+	for word, syllables := range user {
+		t.dict[word] = syllables
+	}
+	for word := range t.dict {
+		if n := utf8.RuneCountInString(word); n > t.maxLen {
+			t.maxLen = n
+		}
+	}
+	return t
+}
+
+// pinyin 汉字串转拼音，词典中的词组优先，其余按单字默认读音
+func (t *phraseTable) pinyin(s string) []string {
+	runes := []rune(s)
+	var out []string
+
+	for i := 0; i < len(runes); {
+		maxLen := t.maxLen
+		if rest := len(runes) - i; rest < maxLen {
+			maxLen = rest
+		}
+
+		matched := false
+		for n := maxLen; n > 0; n-- {
+			if syllables, ok := t.dict[string(runes[i:i+n])]; ok {
+				out = append(out, syllables...)
+				i += n
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			out = append(out, pinyin.LazyPinyin(string(runes[i]), pinyinArgs)...)
+			i++
+		}
+	}
+
+	return out
+}
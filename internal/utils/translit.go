@@ -0,0 +1,284 @@
+package utils
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Transliterate 将文本转写为拉丁字母，其余字符原样保留
+//
+//   - 汉字转拼音（initials 为 true 时只取首字母），音节之间以 sep 分隔
+//   - 日文假名按平文式（Hepburn）罗马字转写
+//   - 韩文按文化观光部 2000 年罗马字（Revised Romanization）转写
+//   - 西里尔字母按常用拉丁转写
+//   - 带重音的拉丁字母去除变音符号（é -> e），全角字母数字转为半角
+//
+// 不同文字之间插入 sep，混排文本中的拉丁字母和数字保持不变
+func Transliterate(text, sep string, initials bool) string {
+	return transliterate(text, sep, initials, defaultPhrases)
+}
+
+// transliterate 使用指定的词组读音表转写
+func transliterate(text, sep string, initials bool, phrases *phraseTable) string {
+	runes := []rune(text)
+	var b strings.Builder
+	b.Grow(len(text))
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case isHan(r):
+			j := scan(runes, i, isHan)
+			b.WriteString(sep)
+			b.WriteString(hanToPinyin(phrases, string(runes[i:j]), sep, initials))
+			b.WriteString(sep)
+			i = j
+
+		case isKana(r):
+			j := scan(runes, i, isKana)
+			b.WriteString(sep)
+			b.WriteString(romanizeKana(runes[i:j]))
+			b.WriteString(sep)
+			i = j
+
+		case isHangul(r):
+			j := scan(runes, i, isHangul)
+			b.WriteString(sep)
+			b.WriteString(romanizeHangul(runes[i:j]))
+			b.WriteString(sep)
+			i = j
+
+		default:
+			b.WriteString(latinize(r))
+			i++
+		}
+	}
+
+	return b.String()
+}
+
+// scan 返回从 i 开始连续满足 fn 的字符的结束位置
+func scan(runes []rune, i int, fn func(rune) bool) int {
+	for i < len(runes) && fn(runes[i]) {
+		i++
+	}
+	return i
+}
+
+// hanToPinyin 汉字串转拼音，initials 为 true 时只取各字首字母
+func hanToPinyin(phrases *phraseTable, s, sep string, initials bool) string {
+	syllables := phrases.pinyin(s)
+	if !initials {
+		return strings.Join(syllables, sep)
+	}
+	var b strings.Builder
+	for _, syllable := range syllables {
+		if syllable != "" {
+			b.WriteByte(syllable[0])
+		}
+	}
+	return b.String()
+}
+
+// --- 拉丁字母与西里尔字母 ---
+
+// latinSpecial NFKD 无法分解的拉丁字母
+var latinSpecial = map[rune]string{
+	'ß': "ss", 'ẞ': "ss", 'æ': "ae", 'Æ': "ae", 'ø': "o", 'Ø': "o",
+	'đ': "d", 'Đ': "d", 'ł': "l", 'Ł': "l", 'œ': "oe", 'Œ': "oe",
+	'þ': "th", 'Þ': "th", 'ð': "d", 'Ð': "d", 'ı': "i", 'ħ': "h", 'Ħ': "h",
+}
+
+// cyrillic 西里尔字母转写表（俄语、乌克兰语、白俄罗斯语）
+var cyrillic = map[rune]string{
+	'а': "a", 'б': "b", 'в': "v", 'г': "g", 'д': "d", 'е': "e", 'ё': "yo",
+	'ж': "zh", 'з': "z", 'и': "i", 'й': "y", 'к': "k", 'л': "l", 'м': "m",
+	'н': "n", 'о': "o", 'п': "p", 'р': "r", 'с': "s", 'т': "t", 'у': "u",
+	'ф': "f", 'х': "kh", 'ц': "ts", 'ч': "ch", 'ш': "sh", 'щ': "shch",
+	'ъ': "", 'ы': "y", 'ь': "", 'э': "e", 'ю': "yu", 'я': "ya",
+	'є': "ye", 'і': "i", 'ї': "yi", 'ґ': "g", 'ў': "u",
+}
+
+// latinize 转写单个非中日韩字符，无法转写时原样返回
+func latinize(r rune) string {
+	if r < 0x80 {
+		return string(r)
+	}
+	if s, ok := latinSpecial[r]; ok {
+		return s
+	}
+	if unicode.Is(unicode.Cyrillic, r) {
+		if s, ok := cyrillic[unicode.ToLower(r)]; ok {
+			return s
+		}
+		return string(r)
+	}
+
+	// 兼容分解后去掉组合附加符号：é -> e，Ａ -> A
+	var b strings.Builder
+	for _, d := range norm.NFKD.String(string(r)) {
+		if !unicode.Is(unicode.Mn, d) {
+			b.WriteRune(d)
+		}
+	}
+	return b.String()
+}
+
+// --- 日文假名 ---
+
+// kana 平假名罗马字（片假名先转换为平假名再查表）
+var kana = map[rune]string{
+	'あ': "a", 'い': "i", 'う': "u", 'え': "e", 'お': "o",
+	'か': "ka", 'き': "ki", 'く': "ku", 'け': "ke", 'こ': "ko",
+	'が': "ga", 'ぎ': "gi", 'ぐ': "gu", 'げ': "ge", 'ご': "go",
+	'さ': "sa", 'し': "shi", 'す': "su", 'せ': "se", 'そ': "so",
+	'ざ': "za", 'じ': "ji", 'ず': "zu", 'ぜ': "ze", 'ぞ': "zo",
+	'た': "ta", 'ち': "chi", 'つ': "tsu", 'て': "te", 'と': "to",
+	'だ': "da", 'ぢ': "ji", 'づ': "zu", 'で': "de", 'ど': "do",
+	'な': "na", 'に': "ni", 'ぬ': "nu", 'ね': "ne", 'の': "no",
+	'は': "ha", 'ひ': "hi", 'ふ': "fu", 'へ': "he", 'ほ': "ho",
+	'ば': "ba", 'び': "bi", 'ぶ': "bu", 'べ': "be", 'ぼ': "bo",
+	'ぱ': "pa", 'ぴ': "pi", 'ぷ': "pu", 'ぺ': "pe", 'ぽ': "po",
+	'ま': "ma", 'み': "mi", 'む': "mu", 'め': "me", 'も': "mo",
+	'や': "ya", 'ゆ': "yu", 'よ': "yo",
+	'ら': "ra", 'り': "ri", 'る': "ru", 'れ': "re", 'ろ': "ro",
+	'わ': "wa", 'ゐ': "i", 'ゑ': "e", 'を': "o", 'ん': "n", 'ゔ': "vu",
+	'ぁ': "a", 'ぃ': "i", 'ぅ': "u", 'ぇ': "e", 'ぉ': "o", 'ゎ': "wa",
+	'ゃ': "ya", 'ゅ': "yu", 'ょ': "yo",
+}
+
+const (
+	smallTsu  = 'っ'
+	longVowel = 'ー'
+)
+
+func isKana(r rune) bool {
+	return (r >= 0x3041 && r <= 0x3096) || (r >= 0x30A1 && r <= 0x30FA) || r == longVowel
+}
+
+// toHiragana 片假名转平假名
+func toHiragana(r rune) rune {
+	if r >= 0x30A1 && r <= 0x30F6 {
+		return r - 0x60
+	}
+	return r
+}
+
+// romanizeKana 假名串转罗马字
+// 处理拗音（きゃ -> kya、しゃ -> sha）、小写元音（ファ -> fa）、促音（っ 双写下一个辅音）和长音符（ー 重复前一个元音）
+func romanizeKana(runes []rune) string {
+	var out []string
+	geminate := false
+
+	for _, r := range runes {
+		r = toHiragana(r)
+		switch r {
+		case smallTsu:
+			geminate = true
+			continue
+		case longVowel:
+			if n := len(out); n > 0 {
+				if v := lastVowel(out[n-1]); v != "" {
+					out = append(out, v)
+				}
+			}
+			continue
+		}
+
+		roma, ok := kana[r]
+		if !ok {
+			continue
+		}
+
+		if n := len(out); n > 0 {
+			prev := out[n-1]
+			switch r {
+			case 'ゃ', 'ゅ', 'ょ':
+				// 拗音：去掉前一音节的 i
+				if strings.HasSuffix(prev, "i") && len(prev) > 1 {
+					stem := strings.TrimSuffix(prev, "i")
+					if strings.HasSuffix(stem, "sh") || strings.HasSuffix(stem, "ch") || strings.HasSuffix(stem, "j") {
+						roma = roma[1:]
+					}
+					out[n-1] = stem + roma
+					continue
+				}
+			case 'ぁ', 'ぃ', 'ぅ', 'ぇ', 'ぉ':
+				// 外来语小写元音：替换前一音节的元音
+				if len(prev) > 1 && lastVowel(prev) != "" {
+					out[n-1] = prev[:len(prev)-1] + roma
+					continue
+				}
+			}
+		}
+
+		if geminate {
+			geminate = false
+			if strings.HasPrefix(roma, "ch") {
+				roma = "t" + roma
+			} else if c := roma[0]; !strings.ContainsRune("aeioun", rune(c)) {
+				roma = string(c) + roma
+			}
+		}
+		out = append(out, roma)
+	}
+
+	return strings.Join(out, "")
+}
+
+// lastVowel 返回罗马字音节的结尾元音
+func lastVowel(s string) string {
+	if s == "" {
+		return ""
+	}
+	if c := s[len(s)-1]; strings.IndexByte("aeiou", c) >= 0 {
+		return string(c)
+	}
+	return ""
+}
+
+// --- 韩文 ---
+
+const (
+	hangulBase  = 0xAC00
+	hangulLast  = 0xD7A3
+	hangulMedN  = 21
+	hangulTailN = 28
+)
+
+var (
+	hangulInitials = []string{
+		"g", "kk", "n", "d", "tt", "r", "m", "b", "pp",
+		"s", "ss", "", "j", "jj", "ch", "k", "t", "p", "h",
+	}
+	hangulMedials = []string{
+		"a", "ae", "ya", "yae", "eo", "e", "yeo", "ye", "o", "wa", "wae",
+		"oe", "yo", "u", "wo", "we", "wi", "yu", "eu", "ui", "i",
+	}
+	// 韵尾按代表音转写
+	hangulFinals = []string{
+		"", "k", "k", "k", "n", "n", "n", "t", "l", "k", "m", "l", "l", "l",
+		"p", "l", "m", "p", "p", "t", "t", "ng", "t", "t", "k", "t", "p", "t",
+	}
+)
+
+func isHangul(r rune) bool {
+	return r >= hangulBase && r <= hangulLast
+}
+
+// romanizeHangul 韩文音节按初声、中声、终声拆分后转写
+func romanizeHangul(runes []rune) string {
+	var b strings.Builder
+	for _, r := range runes {
+		idx := int(r - hangulBase)
+		initial := idx / (hangulMedN * hangulTailN)
+		medial := idx % (hangulMedN * hangulTailN) / hangulTailN
+		final := idx % hangulTailN
+		b.WriteString(hangulInitials[initial])
+		b.WriteString(hangulMedials[medial])
+		b.WriteString(hangulFinals[final])
+	}
+	return b.String()
+}
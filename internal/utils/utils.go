@@ -1,8 +1,11 @@
 package utils
 
 import (
+	"crypto/sha1"
+	"encoding/hex"
 	"fmt"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
@@ -16,46 +19,124 @@ func init() {
 	pinyinArgs.Style = pinyin.Normal // 不带声调
 }
 
+// slug 策略
+const (
+	SlugPinyin   = "pinyin"   // 全拼，如 you-xi-dian-ka
+	SlugInitials = "initials" // 拼音首字母，如 yxdk
+	SlugID       = "id"       // 老版 ID，如 p-123
+	SlugHash     = "hash"     // 名称哈希，如 p-9f86d08188
+	SlugUnicode  = "unicode"  // 保留原文字符，如 游戏点卡
+)
+
+// SlugStrategies 支持的 slug 策略
+var SlugStrategies = []string{SlugPinyin, SlugInitials, SlugID, SlugHash, SlugUnicode}
+
+var (
+	nonAlnum   = regexp.MustCompile(`[^a-zA-Z0-9]+`)
+	nonLetters = regexp.MustCompile(`[^\p{L}\p{N}]+`)
+)
+
+// SlugOptions slug 生成选项
+type SlugOptions struct {
+	Strategy  string // 生成策略，默认 pinyin
+	MaxLength int    // 最大长度（字符数），默认 50
+	Separator string // 分隔符，默认 -
+
+	PinyinDict PinyinDict // 自定义词组读音，优先于内置多音字词组
+}
+
+// Slugger slug 生成器
+type Slugger struct {
+	opts    SlugOptions
+	phrases *phraseTable
+}
+
+// NewSlugger 创建 slug 生成器，未设置的选项使用默认值
+func NewSlugger(opts SlugOptions) *Slugger {
+	if opts.Strategy == "" {
+		opts.Strategy = SlugPinyin
+	}
+	if opts.MaxLength <= 0 {
+		opts.MaxLength = 50
+	}
+	if opts.Separator == "" {
+		opts.Separator = "-"
+	}
+	phrases := defaultPhrases
+	if len(opts.PinyinDict) > 0 {
+		phrases = newPhraseTable(opts.PinyinDict)
+	}
+	return &Slugger{opts: opts, phrases: phrases}
+}
+
+var defaultSlugger = NewSlugger(SlugOptions{})
+
 // Slugify 生成 slug，中文自动转拼音
 func Slugify(text string) string {
-	// 如果包含中文，先转拼音
-	if ContainsChinese(text) {
-		parts := pinyin.LazyPinyin(text, pinyinArgs)
-		if len(parts) > 0 {
-			text = strings.Join(parts, "-")
-		}
-	}
+	return defaultSlugger.Make(text, "item", 0)
+}
+
+// Make 生成 slug
+// kind 为类型前缀（如 c 表示分类、p 表示商品），id 为老版 ID，用于 id/hash 策略
+// 相同输入总是得到相同结果，重复运行时 slug 保持不变
+func (s *Slugger) Make(text, kind string, id int) string {
+	sep := s.opts.Separator
+	var slug string
+
+	switch s.opts.Strategy {
+	case SlugID:
+		slug = fmt.Sprintf("%s%s%d", kind, sep, id)
 
-	// 替换非字母数字为连字符
-	reg := regexp.MustCompile(`[^a-zA-Z0-9]+`)
-	slug := reg.ReplaceAllString(text, "-")
+	case SlugHash:
+		slug = kind + sep + shortHash(text)
 
-	// 去除首尾连字符并转小写
-	slug = strings.Trim(slug, "-")
-	slug = strings.ToLower(slug)
+	case SlugUnicode:
+		slug = nonLetters.ReplaceAllString(strings.ToLower(text), sep)
 
-	// 如果为空，生成时间戳 slug
+	case SlugInitials:
+		// 汉字取拼音首字母（连续汉字合并为一段），其他文字完整转写
+		slug = nonAlnum.ReplaceAllString(strings.ToLower(transliterate(text, sep, true, s.phrases)), sep)
+
+	default:
+		// 汉字转拼音，假名、韩文、西里尔字母和带重音的拉丁字母转写为拉丁字母
+		slug = nonAlnum.ReplaceAllString(strings.ToLower(transliterate(text, sep, false, s.phrases)), sep)
+	}
+
+	// 去除首尾分隔符
+	slug = strings.Trim(slug, sep)
+
+	// 如果为空（如名称全是表情符号），有老版 ID 时用 ID，否则用名称哈希
 	if slug == "" {
-		slug = fmt.Sprintf("item-%s", time.Now().Format("20060102150405"))
+		if id > 0 {
+			slug = fmt.Sprintf("%s%s%d", kind, sep, id)
+		} else {
+			slug = kind + sep + shortHash(text)
+		}
 	}
 
 	// 限制长度
-	if len(slug) > 50 {
-		slug = slug[:50]
-		// 避免截断在连字符处
-		slug = strings.TrimRight(slug, "-")
+	if runes := []rune(slug); len(runes) > s.opts.MaxLength {
+		slug = string(runes[:s.opts.MaxLength])
+		// 避免截断在分隔符处
+		slug = strings.TrimRight(slug, sep)
 	}
 
 	return slug
 }
 
-// EnsureUniqueSlug 确保 slug 唯一
-func EnsureUniqueSlug(slug string, usedSlugs map[string]bool) string {
+// shortHash 名称 SHA-1 的前 10 位
+func shortHash(text string) string {
+	sum := sha1.Sum([]byte(text))
+	return hex.EncodeToString(sum[:])[:10]
+}
+
+// EnsureUniqueSlug 确保 slug 唯一，重复时加分隔符 sep 和序号，如 steam-1
+func EnsureUniqueSlug(slug, sep string, usedSlugs map[string]bool) string {
 	baseSlug := slug
 	counter := 1
 
 	for usedSlugs[slug] {
-		slug = fmt.Sprintf("%s-%d", baseSlug, counter)
+		slug = fmt.Sprintf("%s%s%d", baseSlug, sep, counter)
 		counter++
 	}
 
@@ -63,12 +144,99 @@ func EnsureUniqueSlug(slug string, usedSlugs map[string]bool) string {
 	return slug
 }
 
-// ContainsChinese 检测字符串是否包含中文
+// ContainsChinese 检测字符串是否包含中文（含扩展区和兼容汉字）
 func ContainsChinese(s string) bool {
 	for _, r := range s {
-		if r >= 0x4e00 && r <= 0x9fff {
+		if isHan(r) {
 			return true
 		}
 	}
 	return false
 }
+
+// isHan 判断是否为汉字：基本区、扩展 A 区、兼容汉字及扩展 B 区以后
+func isHan(r rune) bool {
+	switch {
+	case r >= 0x4e00 && r <= 0x9fff: // 基本区
+		return true
+	case r >= 0x3400 && r <= 0x4dbf: // 扩展 A
+		return true
+	case r >= 0xf900 && r <= 0xfaff: // 兼容汉字
+		return true
+	case r >= 0x20000 && r <= 0x323af: // 扩展 B 及以后
+		return true
+	}
+	return false
+}
+
+// IDRange ID 范围（包含两端）
+type IDRange struct {
+	From int
+	To   int
+}
+
+// IDList ID 列表，每项为单个 ID 或范围
+type IDList []IDRange
+
+// ParseIDList 解析 ID 列表，如 "3,5,10-50"，空字符串返回 nil
+func ParseIDList(s string) (IDList, error) {
+	var list IDList
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		from, to, isRange := strings.Cut(part, "-")
+		start, err := strconv.Atoi(strings.TrimSpace(from))
+		if err != nil || start < 0 {
+			return nil, fmt.Errorf("无效的 ID: %q", part)
+		}
+		end := start
+		if isRange {
+			end, err = strconv.Atoi(strings.TrimSpace(to))
+			if err != nil || end < start {
+				return nil, fmt.Errorf("无效的 ID 范围: %q", part)
+			}
+		}
+		list = append(list, IDRange{From: start, To: end})
+	}
+	return list, nil
+}
+
+// String 格式化为 "3,5,10-50"
+func (l IDList) String() string {
+	parts := make([]string, 0, len(l))
+	for _, r := range l {
+		if r.From == r.To {
+			parts = append(parts, strconv.Itoa(r.From))
+		} else {
+			parts = append(parts, fmt.Sprintf("%d-%d", r.From, r.To))
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+// Contains 判断 ID 是否在列表中
+func (l IDList) Contains(id int) bool {
+	for _, r := range l {
+		if id >= r.From && id <= r.To {
+			return true
+		}
+	}
+	return false
+}
+
+// dateLayouts 支持的日期时间格式
+var dateLayouts = []string{"2006-01-02", "2006-01-02 15:04:05", "2006-01-02 15:04", time.RFC3339}
+
+// ParseDate 解析日期或日期时间，如 2024-01-01、2024-01-01 08:00:00
+func ParseDate(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	for _, layout := range dateLayouts {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("无效的日期: %q（格式如 2024-01-01 或 2024-01-01 08:00:00）", s)
+}
@@ -0,0 +1,182 @@
+// Package zhconv 简体中文转繁体中文（离线词典，按 OpenCC 方式分词转换）
+package zhconv
+
+import (
+	"bufio"
+	"embed"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+//go:embed data/*.txt
+var dataFS embed.FS
+
+// conversions 支持的转换方式及其转换链（每一级为一组词典）
+var conversions = map[string][][]string{
+	"s2t":   {{"STPhrases", "STCharacters"}},
+	"s2tw":  {{"STPhrases", "STCharacters"}, {"TWVariants"}},
+	"s2twp": {{"STPhrases", "STCharacters"}, {"TWPhrases"}, {"TWVariants"}},
+	"s2hk":  {{"STPhrases", "STCharacters"}, {"HKVariants"}},
+}
+
+var (
+	dictCache = make(map[string]map[string]string)
+	dictMu    sync.Mutex
+)
+
+// Supported 判断转换方式是否受支持
+func Supported(conversion string) bool {
+	_, ok := conversions[conversion]
+	return ok
+}
+
+// stage 转换链中的一级，按最大正向匹配替换
+type stage struct {
+	dict   map[string]string
+	maxLen int // 最长词条的字符数
+}
+
+// Converter 简繁转换器
+type Converter struct {
+	stages []stage
+}
+
+// New 创建转换器，userDict 为自定义词典路径（可为空），优先级高于内置词典
+func New(conversion, userDict string) (*Converter, error) {
+	chain, ok := conversions[conversion]
+	if !ok {
+		return nil, fmt.Errorf("不支持的简繁转换方式: %s", conversion)
+	}
+
+	var custom map[string]string
+	if userDict != "" {
+		file, err := os.Open(userDict)
+		if err != nil {
+			return nil, fmt.Errorf("读取自定义繁体词典失败: %w", err)
+		}
+		custom, err = parseDict(file)
+		file.Close()
+		if err != nil {
+			return nil, fmt.Errorf("解析自定义繁体词典失败: %w", err)
+		}
+	}
+
+	c := &Converter{}
+	for i, names := range chain {
+		merged := make(map[string]string)
+		for _, name := range names {
+			dict, err := loadDict(name)
+			if err != nil {
+				return nil, err
+			}
+			// 前面的词典优先（词组优先于单字）
+			for k, v := range dict {
+				if _, exists := merged[k]; !exists {
+					merged[k] = v
+				}
+			}
+		}
+		if i == 0 {
+			for k, v := range custom {
+				merged[k] = v
+			}
+		}
+
+		st := stage{dict: merged}
+		for k := range merged {
+			if n := utf8.RuneCountInString(k); n > st.maxLen {
+				st.maxLen = n
+			}
+		}
+		c.stages = append(c.stages, st)
+	}
+
+	return c, nil
+}
+
+// Convert 转换文本，非中文字符原样保留
+func (c *Converter) Convert(text string) string {
+	for _, st := range c.stages {
+		text = st.convert(text)
+	}
+	return text
+}
+
+func (st stage) convert(text string) string {
+	runes := []rune(text)
+	var b strings.Builder
+	b.Grow(len(text))
+
+	for i := 0; i < len(runes); {
+		matched := false
+		maxLen := st.maxLen
+		if rest := len(runes) - i; rest < maxLen {
+			maxLen = rest
+		}
+		for n := maxLen; n > 0; n-- {
+			if to, ok := st.dict[string(runes[i:i+n])]; ok {
+				b.WriteString(to)
+				i += n
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			b.WriteRune(runes[i])
+			i++
+		}
+	}
+
+	return b.String()
+}
+
+// loadDict 加载内置词典（带缓存）
+func loadDict(name string) (map[string]string, error) {
+	dictMu.Lock()
+	defer dictMu.Unlock()
+
+	if dict, ok := dictCache[name]; ok {
+		return dict, nil
+	}
+
+	file, err := dataFS.Open("data/" + name + ".txt")
+	if err != nil {
+		return nil, fmt.Errorf("加载内置词典 %s 失败: %w", name, err)
+	}
+	defer file.Close()
+
+	dict, err := parseDict(file)
+	if err != nil {
+		return nil, fmt.Errorf("解析内置词典 %s 失败: %w", name, err)
+	}
+	dictCache[name] = dict
+	return dict, nil
+}
+
+// parseDict 解析 OpenCC 文本词典：每行 "原文<Tab>候选1 候选2"，取第一个候选
+func parseDict(r io.Reader) (map[string]string, error) {
+	dict := make(map[string]string)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		from, to, ok := strings.Cut(line, "\t")
+		if !ok {
+			fields := strings.Fields(line)
+			if len(fields) < 2 {
+				continue
+			}
+			from, to = fields[0], fields[1]
+		}
+		if candidates := strings.Fields(to); len(candidates) > 0 {
+			dict[strings.TrimSpace(from)] = candidates[0]
+		}
+	}
+	return dict, scanner.Err()
+}
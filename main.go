@@ -1,9 +1,15 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/luoyanglang/dujiao-migrate/internal/config"
 	"github.com/luoyanglang/dujiao-migrate/internal/migrator"
@@ -12,6 +18,14 @@ import (
 const version = "1.0.0"
 
 func main() {
+	// 子命令: migrate（默认）、sync、check-config
+	command := "migrate"
+	cliArgs := os.Args[1:]
+	if len(cliArgs) > 0 && !strings.HasPrefix(cliArgs[0], "-") {
+		command = cliArgs[0]
+		cliArgs = cliArgs[1:]
+	}
+
 	// 命令行参数
 	configFile := flag.String("config", "", "配置文件路径")
 	generateConfig := flag.Bool("generate-config", false, "生成示例配置文件")
@@ -23,7 +37,8 @@ func main() {
 	oldUser := flag.String("old-user", "", "老版数据库用户名")
 	oldPassword := flag.String("old-password", "", "老版数据库密码")
 	oldDatabase := flag.String("old-database", "", "老版数据库名")
-	oldDriver := flag.String("old-driver", "mysql", "老版数据库驱动 (mysql/postgres/sqlite)")
+	oldDriver := flag.String("old-driver", "", "老版数据库驱动 (mysql/postgres/sqlite)，默认 mysql")
+	oldPrefix := flag.String("old-prefix", "", "老版数据表前缀（如 dj_）")
 
 	// 新版 API 参数
 	newAPI := flag.String("new-api", "", "新版 API 地址")
@@ -31,11 +46,36 @@ func main() {
 	newPassword := flag.String("new-password", "", "新版管理员密码")
 
 	// 选项
-	noSkip := flag.Bool("no-skip", false, "不跳过已存在的数据")
+	noSkip := flag.Bool("no-skip", false, "不跳过已存在的数据（等同 --on-existing duplicate）")
+	onExisting := flag.String("on-existing", "", "已存在数据的处理方式 (skip/update/duplicate)")
 	noCards := flag.Bool("no-cards", false, "不迁移卡密")
 	oldSitePath := flag.String("old-site-path", "", "老版站点路径（用于图片迁移）")
 
-	flag.Parse()
+	// 选择性迁移
+	categories := flag.String("categories", "", "只迁移指定的老版分类 ID（如 3,5,10-20）")
+	products := flag.String("products", "", "只迁移指定的老版商品 ID（如 10-50）")
+	where := flag.String("where", "", "商品表附加 SQL 条件（如 \"actual_price > 10\"）")
+	namePattern := flag.String("name-pattern", "", "只迁移名称匹配此正则的商品")
+	createdAfter := flag.String("created-after", "", "只迁移此时间之后创建的商品（如 2024-01-01）")
+
+	// 转换脚本
+	script := flag.String("script", "", "转换脚本（JavaScript），定义 transformCategory/transformProduct/transformCard")
+	currency := flag.String("currency", "", "新版价格货币（如 USD），按 price.rates 换算，默认 CNY")
+
+	// 增量同步
+	watch := flag.Bool("watch", false, "sync 命令: 持续运行，每隔 --interval 同步一次，Ctrl+C 退出")
+	interval := flag.Duration("interval", time.Minute, "sync 命令: 同步间隔（如 30s、5m）")
+
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "用法: %s [命令] [参数]\n\n", os.Args[0])
+		fmt.Fprintln(flag.CommandLine.Output(), "命令:")
+		fmt.Fprintln(flag.CommandLine.Output(), "  migrate        执行迁移（默认）")
+		fmt.Fprintln(flag.CommandLine.Output(), "  sync           增量同步上次同步后老版有变化的数据，配合 --watch 持续运行")
+		fmt.Fprintln(flag.CommandLine.Output(), "  check-config   校验配置并测试数据库连接和 API 登录，不迁移数据")
+		fmt.Fprintln(flag.CommandLine.Output(), "\n参数:")
+		flag.PrintDefaults()
+	}
+	flag.CommandLine.Parse(cliArgs)
 
 	// 显示版本
 	if *showVersion {
@@ -49,6 +89,12 @@ func main() {
 		return
 	}
 
+	switch command {
+	case "migrate", "sync", "check-config":
+	default:
+		log.Fatalf("未知命令: %s（可用命令: migrate, sync, check-config）", command)
+	}
+
 	// 加载配置
 	cfg, err := config.LoadConfig(*configFile, &config.CLIArgs{
 		OldHost:     *oldHost,
@@ -57,17 +103,38 @@ func main() {
 		OldPassword: *oldPassword,
 		OldDatabase: *oldDatabase,
 		OldDriver:   *oldDriver,
+		OldPrefix:   *oldPrefix,
 		NewAPI:      *newAPI,
 		NewUser:     *newUser,
 		NewPassword: *newPassword,
 		NoSkip:      *noSkip,
+		OnExisting:  *onExisting,
 		NoCards:     *noCards,
 		OldSitePath: *oldSitePath,
+
+		Categories:   *categories,
+		Products:     *products,
+		Where:        *where,
+		NamePattern:  *namePattern,
+		CreatedAfter: *createdAfter,
+
+		Script:   *script,
+		Currency: *currency,
 	})
 	if err != nil {
 		log.Fatalf("加载配置失败: %v", err)
 	}
 
+	// 仅检查配置
+	if command == "check-config" {
+		log.Println("✓ 配置校验通过")
+		if err := migrator.Check(cfg); err != nil {
+			log.Fatalf("检查失败: %v", err)
+		}
+		log.Println("检查完成，可以开始迁移")
+		return
+	}
+
 	// 创建迁移器
 	m, err := migrator.New(cfg)
 	if err != nil {
@@ -75,6 +142,20 @@ func main() {
 	}
 	defer m.Close()
 
+	// 增量同步
+	if command == "sync" {
+		if *interval < time.Second {
+			log.Fatalf("同步间隔不能小于 1 秒: %s", *interval)
+		}
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		if err := m.Sync(ctx, *watch, *interval); err != nil {
+			log.Fatalf("同步失败: %v", err)
+		}
+		log.Println("同步完成！")
+		return
+	}
+
 	// 执行迁移
 	if err := m.Run(); err != nil {
 		log.Fatalf("迁移失败: %v", err)